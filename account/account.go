@@ -14,6 +14,12 @@ type Account struct {
 	Rep        types.PubKey
 	Open       types.BlockHash
 	Balance    uint128.Uint128
+	// ConfirmationHeight is the height of the last block
+	// of the chain which has been confirmed.
+	ConfirmationHeight uint64
+	// Epoch is the version the chain has been upgraded
+	// to by epoch blocks, zero if it hasn't been.
+	Epoch uint8
 }
 
 func NewAccount() *Account {
@@ -0,0 +1,98 @@
+package account
+
+import (
+	"encoding/binary"
+
+	"github.com/s1na/nano/store"
+	"github.com/s1na/nano/types"
+	"github.com/s1na/nano/types/uint128"
+
+	"github.com/pkg/errors"
+)
+
+// accountVersion is the version of the stored account encoding.
+// A stored account is the version, public key, head, representative,
+// open block, balance, confirmation height and epoch, followed by the
+// length of the private key and the key itself, which ledger accounts
+// don't have. Version 2 lacked the epoch, and version 1 also lacked
+// the confirmation height.
+const (
+	accountVersion   byte = 3
+	accountVersionV2 byte = 2
+	accountVersionV1 byte = 1
+)
+
+const (
+	accountSize   = 1 + 32 + 32 + 32 + 32 + 16 + 8 + 1 + 1
+	accountSizeV2 = accountSize - 1
+	accountSizeV1 = accountSizeV2 - 8
+)
+
+func encodeAccount(a *Account) []byte {
+	v := make([]byte, 0, accountSize+len(a.PrivateKey))
+	v = append(v, accountVersion)
+	v = append(v, keyBytes(a.PublicKey)...)
+	v = append(v, a.Head[:]...)
+	v = append(v, keyBytes(a.Rep)...)
+	v = append(v, a.Open[:]...)
+	v = append(v, a.Balance.GetBytes()...)
+
+	var height [8]byte
+	binary.BigEndian.PutUint64(height[:], a.ConfirmationHeight)
+	v = append(v, height[:]...)
+	v = append(v, a.Epoch)
+
+	v = append(v, byte(len(a.PrivateKey)))
+	v = append(v, a.PrivateKey...)
+
+	return v
+}
+
+func decodeAccount(v []byte) (*Account, error) {
+	if len(v) < 1 {
+		return nil, store.ErrCorrupt
+	}
+
+	var size int
+	switch v[0] {
+	case accountVersion:
+		size = accountSize
+	case accountVersionV2:
+		size = accountSizeV2
+	case accountVersionV1:
+		size = accountSizeV1
+	default:
+		return nil, errors.Errorf("unsupported account encoding version %d", v[0])
+	}
+
+	if len(v) < size || len(v) != size+int(v[size-1]) {
+		return nil, store.ErrCorrupt
+	}
+
+	a := NewAccount()
+	a.PublicKey = types.PubKeyFromSlice(v[1:33])
+	a.Head = types.BlockHashFromSlice(v[33:65])
+	a.Rep = types.PubKeyFromSlice(v[65:97])
+	a.Open = types.BlockHashFromSlice(v[97:129])
+	a.Balance = uint128.FromBytes(v[129:145])
+	if v[0] >= accountVersionV2 {
+		a.ConfirmationHeight = binary.BigEndian.Uint64(v[145:153])
+	}
+	if v[0] >= accountVersion {
+		a.Epoch = v[153]
+	}
+	if len(v) > size {
+		a.PrivateKey = types.PrvKeyFromSlice(append([]byte{}, v[size:]...))
+	}
+
+	return a, nil
+}
+
+// keyBytes returns k as exactly 32 bytes, so that
+// unset keys are encoded as zeros.
+func keyBytes(k types.PubKey) []byte {
+	res := make([]byte, 32)
+	copy(res, k)
+
+	return res
+}
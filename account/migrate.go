@@ -0,0 +1,51 @@
+package account
+
+import (
+	"bytes"
+	"encoding/gob"
+
+	"github.com/s1na/nano/store"
+)
+
+func init() {
+	store.RegisterMigration(store.Migration{
+		Version: 1,
+		Name:    "binary account encoding",
+		Prefix:  []byte("account:"),
+		Migrate: migrateGobAccount,
+	})
+	store.RegisterMigration(store.Migration{
+		Version: 4,
+		Name:    "account confirmation height",
+		Prefix:  []byte("account:"),
+		Migrate: migrateAccountEncoding,
+	})
+	store.RegisterMigration(store.Migration{
+		Version: 5,
+		Name:    "account epoch",
+		Prefix:  []byte("account:"),
+		Migrate: migrateAccountEncoding,
+	})
+}
+
+// migrateGobAccount re-encodes an account,
+// as they were gob encoded before version 1.
+func migrateGobAccount(txn store.Txn, k, v []byte) error {
+	var a *Account
+	if err := gob.NewDecoder(bytes.NewBuffer(v)).Decode(&a); err != nil {
+		return err
+	}
+
+	return txn.Set(k, encodeAccount(a))
+}
+
+// migrateAccountEncoding re-encodes an account in the current
+// encoding, in which the fields it lacked have their zero value.
+func migrateAccountEncoding(txn store.Txn, k, v []byte) error {
+	a, err := decodeAccount(v)
+	if err != nil {
+		return err
+	}
+
+	return txn.Set(k, encodeAccount(a))
+}
@@ -1,18 +1,15 @@
 package account
 
 import (
-	"bytes"
-	"encoding/gob"
-
 	"github.com/s1na/nano/store"
 	"github.com/s1na/nano/types"
 )
 
 type AccountStore struct {
-	s *store.Store
+	s store.ReadWriter
 }
 
-func NewAccountStore(store *store.Store) *AccountStore {
+func NewAccountStore(store store.ReadWriter) *AccountStore {
 	s := new(AccountStore)
 
 	s.s = store
@@ -21,13 +18,7 @@ func NewAccountStore(store *store.Store) *AccountStore {
 }
 
 func (s *AccountStore) SetAccount(a *Account) error {
-	var buf bytes.Buffer
-	enc := gob.NewEncoder(&buf)
-	if err := enc.Encode(a); err != nil {
-		return err
-	}
-
-	return s.s.Set(append([]byte("account:"), a.PublicKey...), buf.Bytes())
+	return s.s.Set(append([]byte("account:"), a.PublicKey...), encodeAccount(a))
 }
 
 func (s *AccountStore) GetAccount(pub types.PubKey) (*Account, error) {
@@ -36,13 +27,46 @@ func (s *AccountStore) GetAccount(pub types.PubKey) (*Account, error) {
 		return nil, err
 	}
 
-	buf := bytes.NewBuffer(v)
-	dec := gob.NewDecoder(buf)
+	return decodeAccount(v)
+}
 
-	var a *Account
-	if err = dec.Decode(&a); err != nil {
+func (s *AccountStore) GetAccounts() ([]*Account, error) {
+	res, err := s.s.GetPrefixValues([]byte("account:"))
+	if err != nil {
 		return nil, err
 	}
 
-	return a, nil
+	accounts := make([]*Account, 0, len(res))
+	for _, v := range res {
+		a, err := decodeAccount(v)
+		if err != nil {
+			return nil, err
+		}
+
+		accounts = append(accounts, a)
+	}
+
+	return accounts, nil
+}
+
+// Iterate calls fn for all accounts, ordered by public key.
+func (s *AccountStore) Iterate(fn func(a *Account) error) error {
+	return s.IterateFrom(nil, fn)
+}
+
+// IterateFrom is like Iterate, starting at the first
+// account whose public key isn't below start.
+func (s *AccountStore) IterateFrom(start types.PubKey, fn func(a *Account) error) error {
+	return s.s.IterateFrom([]byte("account:"), start, func(k, v []byte) error {
+		a, err := decodeAccount(v)
+		if err != nil {
+			return err
+		}
+
+		return fn(a)
+	})
+}
+
+func (s *AccountStore) DeleteAccount(pub types.PubKey) error {
+	return s.s.Delete(append([]byte("account:"), pub...))
 }
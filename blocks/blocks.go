@@ -2,10 +2,12 @@ package blocks
 
 import (
 	"encoding/json"
+	"fmt"
 
 	"github.com/s1na/nano/types"
 	"github.com/s1na/nano/types/uint128"
 
+	"github.com/frankh/crypto/ed25519"
 	"github.com/golang/crypto/blake2b"
 	"github.com/pkg/errors"
 )
@@ -46,6 +48,9 @@ const (
 	Send              = "send"
 	Change            = "change"
 	Utx               = "utx"
+	// State is the name the reference node uses
+	// in json for universal blocks.
+	State = "state"
 )
 
 type Block interface {
@@ -81,6 +86,7 @@ type RawBlock struct {
 	Previous       types.BlockHash
 	Balance        uint128.Uint128
 	Destination    types.PubKey
+	Link           types.BlockHash
 }
 
 func FromJson(b []byte) (Block, error) {
@@ -122,6 +128,15 @@ func FromJson(b []byte) (Block, error) {
 			raw.Representative,
 			common,
 		}
+	case Utx, State:
+		block = &UtxBlock{
+			raw.Account,
+			raw.Previous,
+			raw.Representative,
+			raw.Balance,
+			types.PubKeyFromSlice(raw.Link[:]),
+			common,
+		}
 	default:
 		return nil, errors.New("unknown block type")
 	}
@@ -139,6 +154,8 @@ func (b RawBlock) Hash() [32]byte {
 		return HashReceive(b.Previous, b.Source)
 	case Change:
 		return HashChange(b.Previous, b.Representative)
+	case Utx, State:
+		return HashUtx(b.Account, b.Previous, b.Representative, b.Balance, types.PubKeyFromSlice(b.Link[:]))
 	default:
 		panic("Unknown block type! " + b.Type)
 	}
@@ -175,6 +192,29 @@ func HashBytes(inputs ...[]byte) types.BlockHash {
 	return types.BlockHashFromSlice(hash.Sum(nil))
 }
 
+// SignatureError is returned for blocks whose signature
+// wasn't made by the account owning them.
+type SignatureError struct {
+	Hash    types.BlockHash
+	Account types.PubKey
+}
+
+func (e *SignatureError) Error() string {
+	return fmt.Sprintf("invalid signature on block %s for account %s", e.Hash, e.Account.Address())
+}
+
+// VerifySignature checks the signature of the block against the
+// account owning it. Legacy send, receive and change blocks don't
+// state their account, so callers have to resolve it from the chain.
+func VerifySignature(b Block, pub types.PubKey) error {
+	sig := b.GetSignature()
+	if len(pub) != ed25519.PublicKeySize || !ed25519.Verify(ed25519.PublicKey(pub), b.Hash().Slice(), sig[:]) {
+		return &SignatureError{b.Hash(), pub}
+	}
+
+	return nil
+}
+
 func ValidateBlockWork(b Block) bool {
 	return b.GetWork().Validate(b.GetRoot())
 }
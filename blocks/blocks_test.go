@@ -26,6 +26,33 @@ func TestSignMessage(t *testing.T) {
 	assert.EqualValues(t, sig, block.GetSignature())
 }
 
+func TestUtxFromJson(t *testing.T) {
+	block, err := FromJson([]byte(`{
+		"type":           "state",
+		"account":        "xrb_3e3j5tkog48pnny9dmfzj1r16pg8t1e76dz5tmac6iq689wyjfpiij4txtdo",
+		"previous":       "B0311EA55708D6A53C75CDBF88300259C6D018522FE3D4D0A242E431F9E8B6D0",
+		"representative": "xrb_3e3j5tkog48pnny9dmfzj1r16pg8t1e76dz5tmac6iq689wyjfpiij4txtdo",
+		"link":           "E89208DD038FBB269987689621D52292AE9C35941A7484756ECCED92A65093BA",
+		"work":           "9680625b39d3363d"
+	}`))
+	require.Nil(t, err)
+
+	b, ok := block.(*UtxBlock)
+	require.True(t, ok)
+	assert.Equal(t, TestGenesisBlock.Account, b.Account)
+	assert.Equal(t, "E89208DD038FBB269987689621D52292AE9C35941A7484756ECCED92A65093BA", b.LinkHash().String())
+	assert.False(t, b.IsOpen())
+
+	// The preamble keeps universal hashes apart from legacy ones
+	legacy := HashBytes(b.Account, b.Previous[:], b.Representative, b.Balance.GetBytes(), b.Link)
+	assert.NotEqual(t, legacy, b.Hash())
+
+	b.Signature, err = SignMessage(TestPrivateKey, b.Hash().Slice())
+	require.Nil(t, err)
+	passed, _ := b.VerifySignature()
+	assert.True(t, passed)
+}
+
 func TestHashOpen(t *testing.T) {
 	assert.Equal(t, LiveGenesisBlockHash, LiveGenesisBlock.Hash())
 }
@@ -57,3 +84,39 @@ func TestValidateWork(t *testing.T) {
 	assert.True(t, liveWork.Validate(lbh))
 	assert.False(t, liveBadWork.Validate(lbh))
 }
+
+func TestEncodeDecode(t *testing.T) {
+	utx, err := FromJson([]byte(`{
+		"type":           "state",
+		"account":        "xrb_3e3j5tkog48pnny9dmfzj1r16pg8t1e76dz5tmac6iq689wyjfpiij4txtdo",
+		"previous":       "B0311EA55708D6A53C75CDBF88300259C6D018522FE3D4D0A242E431F9E8B6D0",
+		"representative": "xrb_3e3j5tkog48pnny9dmfzj1r16pg8t1e76dz5tmac6iq689wyjfpiij4txtdo",
+		"link":           "E89208DD038FBB269987689621D52292AE9C35941A7484756ECCED92A65093BA",
+		"work":           "9680625b39d3363d"
+	}`))
+	require.Nil(t, err)
+
+	// The sideband keeps the account of legacy blocks
+	send := &SendBlock{TestGenesisBlock.Hash(), TestGenesisBlock.Account, GenesisAmount, CommonBlock{}}
+	sb := &Sideband{
+		Account:   TestGenesisBlock.Account,
+		Successor: TestGenesisBlock.Hash(),
+		Height:    2,
+		Balance:   GenesisAmount,
+		Timestamp: 1524000000,
+	}
+
+	for _, b := range []Block{TestGenesisBlock, send, utx} {
+		v, err := encodeBlock(b, sb)
+		require.Nil(t, err)
+		assert.Equal(t, byte(blockVersion), v[0])
+
+		d, dsb, err := decodeBlock(v)
+		require.Nil(t, err)
+		assert.Equal(t, b, d)
+		assert.Equal(t, sb, dsb)
+	}
+
+	_, _, err = decodeBlock([]byte{blockVersion, 2, 0})
+	assert.NotNil(t, err)
+}
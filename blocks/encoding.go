@@ -0,0 +1,285 @@
+package blocks
+
+import (
+	"encoding/binary"
+
+	"github.com/s1na/nano/store"
+	"github.com/s1na/nano/types"
+	"github.com/s1na/nano/types/uint128"
+
+	"github.com/pkg/errors"
+)
+
+// Sizes of the blocks in their wire layout, without the type.
+const (
+	SendSize    = 32 + 32 + 16 + 64 + 8
+	OpenSize    = 32 + 32 + 32 + 64 + 8
+	ChangeSize  = 32 + 32 + 64 + 8
+	ReceiveSize = 32 + 32 + 64 + 8
+	UtxSize     = 32 + 32 + 32 + 16 + 32 + 64 + 8
+)
+
+// blockVersion is the version of the stored block encoding.
+// A stored block is the version, the type id and the block
+// in its wire layout, followed by the sideband. The sideband
+// of version 1 only held the account.
+const (
+	blockVersion   byte = 2
+	blockVersionV1 byte = 1
+)
+
+const (
+	sidebandSize   = 32 + 32 + 8 + 16 + 8
+	sidebandSizeV1 = 32
+)
+
+// Type ids of the blocks, as they appear in message headers.
+var typeIds = map[BlockType]byte{
+	Send:    2,
+	Receive: 3,
+	Open:    4,
+	Change:  5,
+	Utx:     6,
+}
+
+// Sideband holds what is stored with a block but
+// is not part of it, and is derived by the ledger.
+type Sideband struct {
+	// Account owning the block, which legacy
+	// send, receive and change blocks lack.
+	Account types.PubKey
+	// Successor is the next block in the chain,
+	// or zero for the head.
+	Successor types.BlockHash
+	// Height is the position of the block in
+	// its chain, starting with 1 for the open.
+	Height uint64
+	// Balance of the account after the block.
+	Balance uint128.Uint128
+	// Timestamp is the unix time the block
+	// was stored at.
+	Timestamp int64
+}
+
+// TypeId returns the id of the block type used in
+// message headers and in the store.
+func TypeId(t BlockType) (byte, bool) {
+	id, ok := typeIds[t]
+	return id, ok
+}
+
+// TypeFromId is the inverse of TypeId.
+func TypeFromId(id byte) (BlockType, bool) {
+	for t, i := range typeIds {
+		if i == id {
+			return t, true
+		}
+	}
+
+	return "", false
+}
+
+// MarshalBinary encodes b in its wire layout, without the type.
+func MarshalBinary(b Block) ([]byte, error) {
+	data := make([]byte, 0, UtxSize)
+
+	switch b := b.(type) {
+	case *SendBlock:
+		data = append(data, b.Previous[:]...)
+		data = append(data, pubKeyBytes(b.Destination)...)
+		data = append(data, b.Balance.GetBytes()...)
+	case *OpenBlock:
+		data = append(data, b.Source[:]...)
+		data = append(data, pubKeyBytes(b.Representative)...)
+		data = append(data, pubKeyBytes(b.Account)...)
+	case *ChangeBlock:
+		data = append(data, b.Previous[:]...)
+		data = append(data, pubKeyBytes(b.Representative)...)
+	case *ReceiveBlock:
+		data = append(data, b.Previous[:]...)
+		data = append(data, b.Source[:]...)
+	case *UtxBlock:
+		data = append(data, pubKeyBytes(b.Account)...)
+		data = append(data, b.Previous[:]...)
+		data = append(data, pubKeyBytes(b.Representative)...)
+		data = append(data, b.Balance.GetBytes()...)
+		data = append(data, pubKeyBytes(b.Link)...)
+		data = append(data, b.Signature[:]...)
+		// Universal blocks carry their work big endian
+		data = append(data, types.Reversed(b.Work[:])...)
+
+		return data, nil
+	default:
+		return nil, errors.New("unknown block type")
+	}
+
+	sig := b.GetSignature()
+	work := b.GetWork()
+	data = append(data, sig[:]...)
+	data = append(data, work[:]...)
+
+	return data, nil
+}
+
+// UnmarshalBinary decodes a block of type t from its wire layout.
+func UnmarshalBinary(t BlockType, data []byte) (Block, error) {
+	invalidErr := errors.New("invalid block")
+
+	var size int
+	switch t {
+	case Send:
+		size = SendSize
+	case Open:
+		size = OpenSize
+	case Change:
+		size = ChangeSize
+	case Receive:
+		size = ReceiveSize
+	case Utx:
+		size = UtxSize
+	default:
+		return nil, errors.New("unknown block type")
+	}
+
+	if len(data) != size {
+		return nil, invalidErr
+	}
+
+	common := CommonBlock{
+		Signature: types.SignatureFromSlice(data[size-72 : size-8]),
+		Work:      types.WorkFromSlice(data[size-8:]),
+	}
+
+	switch t {
+	case Send:
+		return &SendBlock{
+			types.BlockHashFromSlice(data[:32]),
+			types.PubKeyFromSlice(data[32:64]),
+			uint128.FromBytes(data[64:80]),
+			common,
+		}, nil
+	case Open:
+		return &OpenBlock{
+			types.BlockHashFromSlice(data[:32]),
+			types.PubKeyFromSlice(data[32:64]),
+			types.PubKeyFromSlice(data[64:96]),
+			common,
+		}, nil
+	case Change:
+		return &ChangeBlock{
+			types.BlockHashFromSlice(data[:32]),
+			types.PubKeyFromSlice(data[32:64]),
+			common,
+		}, nil
+	case Receive:
+		return &ReceiveBlock{
+			types.BlockHashFromSlice(data[:32]),
+			types.BlockHashFromSlice(data[32:64]),
+			common,
+		}, nil
+	default:
+		common.Work = types.WorkFromSlice(types.Reversed(data[size-8:]))
+		return &UtxBlock{
+			types.PubKeyFromSlice(data[:32]),
+			types.BlockHashFromSlice(data[32:64]),
+			types.PubKeyFromSlice(data[64:96]),
+			uint128.FromBytes(data[96:112]),
+			types.PubKeyFromSlice(data[112:144]),
+			common,
+		}, nil
+	}
+}
+
+func encodeBlock(b Block, sb *Sideband) ([]byte, error) {
+	id, ok := TypeId(b.Type())
+	if !ok {
+		return nil, errors.New("unknown block type")
+	}
+
+	body, err := MarshalBinary(b)
+	if err != nil {
+		return nil, err
+	}
+
+	if sb == nil {
+		sb = new(Sideband)
+	}
+
+	v := make([]byte, 0, 2+len(body)+sidebandSize)
+	v = append(v, blockVersion, id)
+	v = append(v, body...)
+	v = append(v, pubKeyBytes(sb.Account)...)
+	v = append(v, sb.Successor[:]...)
+	v = appendUint64(v, sb.Height)
+	v = append(v, sb.Balance.GetBytes()...)
+	v = appendUint64(v, uint64(sb.Timestamp))
+
+	return v, nil
+}
+
+func decodeBlock(v []byte) (Block, *Sideband, error) {
+	if len(v) < 2 {
+		return nil, nil, store.ErrCorrupt
+	}
+
+	var size int
+	switch v[0] {
+	case blockVersion:
+		size = sidebandSize
+	case blockVersionV1:
+		size = sidebandSizeV1
+	default:
+		return nil, nil, errors.Errorf("unsupported block encoding version %d", v[0])
+	}
+
+	t, ok := TypeFromId(v[1])
+	if !ok || len(v) < 2+size {
+		return nil, nil, store.ErrCorrupt
+	}
+
+	b, err := UnmarshalBinary(t, v[2:len(v)-size])
+	if err != nil {
+		return nil, nil, store.ErrCorrupt
+	}
+
+	sbv := v[len(v)-size:]
+	sb := new(Sideband)
+	if acc := types.PubKeyFromSlice(sbv[:32]); !isZeroKey(acc) {
+		sb.Account = acc
+	}
+
+	if v[0] == blockVersion {
+		sb.Successor = types.BlockHashFromSlice(sbv[32:64])
+		sb.Height = binary.BigEndian.Uint64(sbv[64:72])
+		sb.Balance = uint128.FromBytes(sbv[72:88])
+		sb.Timestamp = int64(binary.BigEndian.Uint64(sbv[88:96]))
+	}
+
+	return b, sb, nil
+}
+
+func appendUint64(v []byte, n uint64) []byte {
+	var b [8]byte
+	binary.BigEndian.PutUint64(b[:], n)
+
+	return append(v, b[:]...)
+}
+
+// pubKeyBytes returns k as exactly 32 bytes, so that
+// unset keys are encoded as zeros.
+func pubKeyBytes(k types.PubKey) []byte {
+	res := make([]byte, 32)
+	copy(res, k)
+
+	return res
+}
+
+func isZeroKey(k types.PubKey) bool {
+	for _, b := range k {
+		if b != 0 {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,40 @@
+package blocks
+
+import (
+	"bytes"
+
+	"github.com/s1na/nano/types"
+)
+
+// Epoch1 is the version of account chains
+// which have been upgraded by an epoch block.
+const Epoch1 uint8 = 1
+
+// EpochLink marks universal blocks as epoch blocks, which upgrade
+// an account chain without changing its balance or representative,
+// and are signed by EpochSigner instead of the account owner.
+var EpochLink = epochLink("epoch v1 block")
+
+// EpochSigner is the account allowed to sign epoch blocks.
+var EpochSigner types.PubKey
+
+// IsEpoch reports whether the block is an epoch block.
+func (b *UtxBlock) IsEpoch() bool {
+	return bytes.Equal(b.Link, EpochLink)
+}
+
+// Signer returns the account which has to sign the block.
+func (b *UtxBlock) Signer() types.PubKey {
+	if b.IsEpoch() {
+		return EpochSigner
+	}
+
+	return b.Account
+}
+
+func epochLink(s string) types.PubKey {
+	link := make([]byte, 32)
+	copy(link, s)
+
+	return types.PubKeyFromSlice(link)
+}
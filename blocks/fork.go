@@ -0,0 +1,132 @@
+package blocks
+
+import (
+	"github.com/s1na/nano/store"
+	"github.com/s1na/nano/types"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+var ErrFork = errors.New("block root already has a successor")
+
+// DetectFork checks whether another block already succeeds the
+// root of b. If so, both competitors are recorded as a conflict
+// on that root, and ErrFork is returned.
+func (s *BlockStore) DetectFork(b Block) error {
+	root := b.GetRoot()
+	v, err := s.s.Get(rootKey(root))
+	if err != nil {
+		if err == store.ErrKeyNotFound {
+			return nil
+		}
+
+		return err
+	}
+
+	hash := types.BlockHashFromSlice(v)
+	if hash == b.Hash() {
+		return nil
+	}
+
+	existing, err := s.GetBlock(hash)
+	if err != nil {
+		// Pruned blocks are confirmed, so there's nothing to resolve
+		if err == ErrPruned {
+			return ErrFork
+		}
+
+		return errors.Wrap(err, "failed to fetch successor of root")
+	}
+
+	for _, c := range []Block{existing, b} {
+		v, err := encodeBlock(c, nil)
+		if err != nil {
+			return err
+		}
+
+		if err := s.s.Set(conflictKey(root, c.Hash()), v); err != nil {
+			return err
+		}
+	}
+
+	log.WithFields(log.Fields{
+		"root":     root.String(),
+		"existing": hash.String(),
+		"incoming": b.Hash().String(),
+	}).Warn("Detected fork")
+
+	return ErrFork
+}
+
+// GetConflict returns the competing blocks recorded for root.
+func (s *BlockStore) GetConflict(root types.BlockHash) ([]Block, error) {
+	res, err := s.s.GetPrefixValues(conflictRootKey(root))
+	if err != nil {
+		return nil, err
+	}
+
+	conflict := make([]Block, 0, len(res))
+	for _, v := range res {
+		b, _, err := decodeBlock(v)
+		if err != nil {
+			return nil, err
+		}
+
+		conflict = append(conflict, b)
+	}
+
+	return conflict, nil
+}
+
+// GetForks returns all active forks, keyed by their root.
+func (s *BlockStore) GetForks() (map[types.BlockHash][]Block, error) {
+	res, err := s.s.GetPrefixValues([]byte(conflictPrefix))
+	if err != nil {
+		return nil, err
+	}
+
+	forks := make(map[types.BlockHash][]Block)
+	for k, v := range res {
+		b, _, err := decodeBlock(v)
+		if err != nil {
+			return nil, err
+		}
+
+		root := types.BlockHashFromSlice([]byte(k)[len(conflictPrefix):])
+		forks[root] = append(forks[root], b)
+	}
+
+	return forks, nil
+}
+
+// DeleteConflict drops the conflict recorded for root,
+// once it has been resolved.
+func (s *BlockStore) DeleteConflict(root types.BlockHash) error {
+	res, err := s.s.GetPrefixValues(conflictRootKey(root))
+	if err != nil {
+		return err
+	}
+
+	for k := range res {
+		if err := s.s.Delete([]byte(k)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+const conflictPrefix = "conflict:"
+
+func conflictKey(root types.BlockHash, hash types.BlockHash) []byte {
+	return append(conflictRootKey(root), hash.Slice()...)
+}
+
+func conflictRootKey(root types.BlockHash) []byte {
+	return append([]byte(conflictPrefix), root.Slice()...)
+}
+
+func rootKey(root types.BlockHash) []byte {
+	return append([]byte("root:"), root.Slice()...)
+}
@@ -0,0 +1,60 @@
+package blocks
+
+import (
+	"bytes"
+	"encoding/gob"
+
+	"github.com/s1na/nano/store"
+)
+
+func init() {
+	// Blocks were gob encoded before version 1, which needs the
+	// block types registered to decode the Block interface.
+	gob.Register(&OpenBlock{})
+	gob.Register(&SendBlock{})
+	gob.Register(&ChangeBlock{})
+	gob.Register(&ReceiveBlock{})
+	gob.Register(&UtxBlock{})
+
+	store.RegisterMigration(store.Migration{
+		Version: 1,
+		Name:    "binary block encoding",
+		Prefix:  []byte("block:"),
+		Migrate: migrateGobBlock(0),
+	})
+	store.RegisterMigration(store.Migration{
+		Version: 1,
+		Name:    "binary conflict encoding",
+		Prefix:  []byte(conflictPrefix),
+		Migrate: migrateGobBlock(0),
+	})
+	// Unchecked blocks are preceded by the time they were added
+	store.RegisterMigration(store.Migration{
+		Version: 1,
+		Name:    "binary unchecked encoding",
+		Prefix:  []byte(uncheckedPrefix),
+		Migrate: migrateGobBlock(8),
+	})
+}
+
+// migrateGobBlock returns the migration re-encoding a gob encoded
+// block, which follows skip bytes of the stored value.
+func migrateGobBlock(skip int) func(txn store.Txn, k, v []byte) error {
+	return func(txn store.Txn, k, v []byte) error {
+		if len(v) < skip {
+			return store.ErrCorrupt
+		}
+
+		var b Block
+		if err := gob.NewDecoder(bytes.NewBuffer(v[skip:])).Decode(&b); err != nil {
+			return err
+		}
+
+		enc, err := encodeBlock(b, nil)
+		if err != nil {
+			return err
+		}
+
+		return txn.Set(k, append(v[:skip:skip], enc...))
+	}
+}
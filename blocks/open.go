@@ -1,7 +1,6 @@
 package blocks
 
 import (
-	"github.com/frankh/crypto/ed25519"
 	"github.com/s1na/nano/types"
 )
 
@@ -29,7 +28,7 @@ func (*OpenBlock) Type() BlockType {
 }
 
 func (b *OpenBlock) VerifySignature() (bool, error) {
-	return ed25519.Verify(ed25519.PublicKey(b.Account), b.Hash().Slice(), b.Signature[:]), nil
+	return VerifySignature(b, b.Account) == nil, nil
 }
 
 func HashOpen(source types.BlockHash, representative types.PubKey, account types.PubKey) types.BlockHash {
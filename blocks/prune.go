@@ -0,0 +1,116 @@
+package blocks
+
+import (
+	"github.com/s1na/nano/store"
+	"github.com/s1na/nano/types"
+
+	"github.com/pkg/errors"
+)
+
+var ErrPruned = errors.New("block has been pruned")
+
+// errFound stops iterating once a key has been found.
+var errFound = errors.New("found")
+
+const prunedPrefix = "pruned:"
+
+// PruneBlock deletes the block with the given hash, leaving behind
+// the account it belonged to, so that it can still be told apart
+// from unknown blocks. Its root stays taken, so that blocks
+// competing with it are still detected as forks. The representative
+// of the account after the block is left behind as well if rep is
+// set, which is done for the newest pruned block of a chain, so that
+// the blocks kept after it can still be rolled back.
+//
+// The tombstone is kept for every pruned block rather than only at
+// the chain boundary, as receiving a pruned send looks up the account
+// of the send itself. It costs the 39 byte key and the 32 byte
+// account, down from the few hundred bytes of a stored block.
+func (s *BlockStore) PruneBlock(hash types.BlockHash, rep types.PubKey) error {
+	_, sb, err := s.getBlock(hash)
+	if err != nil {
+		return err
+	}
+
+	v := pubKeyBytes(sb.Account)
+	if len(rep) != 0 {
+		v = append(v, pubKeyBytes(rep)...)
+	}
+
+	if err := s.s.Set(prunedKey(hash), v); err != nil {
+		return err
+	}
+
+	return s.s.Delete(blockKey(hash))
+}
+
+// PrunedAccount returns the account the pruned block
+// with the given hash belonged to.
+func (s *BlockStore) PrunedAccount(hash types.BlockHash) (types.PubKey, error) {
+	v, err := s.getPruned(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	return types.PubKeyFromSlice(v[:32]), nil
+}
+
+// PrunedRepresentative returns the representative of the account after
+// the pruned block with the given hash, which is only known for the
+// newest pruned block of a chain. ErrPruned is returned otherwise.
+func (s *BlockStore) PrunedRepresentative(hash types.BlockHash) (types.PubKey, error) {
+	v, err := s.getPruned(hash)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(v) != 64 {
+		return nil, ErrPruned
+	}
+
+	return types.PubKeyFromSlice(v[32:]), nil
+}
+
+func (s *BlockStore) getPruned(hash types.BlockHash) ([]byte, error) {
+	v, err := s.s.Get(prunedKey(hash))
+	if err != nil {
+		return nil, err
+	}
+
+	if len(v) != 32 && len(v) != 64 {
+		return nil, store.ErrCorrupt
+	}
+
+	return v, nil
+}
+
+// Pruned reports whether any block has been pruned.
+func (s *BlockStore) Pruned() (bool, error) {
+	found := false
+	err := s.s.Iterate([]byte(prunedPrefix), func(k, v []byte) error {
+		found = true
+		return errFound
+	})
+	if err != nil && err != errFound {
+		return false, err
+	}
+
+	return found, nil
+}
+
+// pruned reports whether the block with the given hash has been pruned.
+func (s *BlockStore) pruned(hash types.BlockHash) (bool, error) {
+	if _, err := s.s.Get(prunedKey(hash)); err != nil {
+		if err == store.ErrKeyNotFound {
+			return false, nil
+		}
+
+		return false, err
+	}
+
+	return true, nil
+}
+
+func prunedKey(hash types.BlockHash) []byte {
+	return append([]byte(prunedPrefix), hash.Slice()...)
+}
@@ -1,87 +1,188 @@
 package blocks
 
 import (
-	"bytes"
-	"encoding/gob"
+	"time"
 
 	"github.com/s1na/nano/store"
 	"github.com/s1na/nano/types"
 
-	"github.com/dgraph-io/badger"
 	"github.com/pkg/errors"
-	log "github.com/sirupsen/logrus"
+)
+
+var (
+	ErrMissingPrevious = errors.New("cannot find previous block")
+	ErrInvalidWork     = errors.New("invalid block work")
+	ErrUnknownType     = errors.New("unknown block type")
 )
 
 type BlockStore struct {
-	s            *store.Store
-	orphanBlocks map[types.BlockHash]Block
+	s store.ReadWriter
 }
 
-func NewBlockStore(store *store.Store) *BlockStore {
+func NewBlockStore(store store.ReadWriter) *BlockStore {
 	s := new(BlockStore)
 
 	s.s = store
-	s.orphanBlocks = make(map[types.BlockHash]Block)
-
-	// Register block types for gob, so it encodes
-	// and decodes the Block interface.
-	gob.Register(&OpenBlock{})
-	gob.Register(&SendBlock{})
-	gob.Register(&ChangeBlock{})
-	gob.Register(&ReceiveBlock{})
 
 	return s
 }
 
-func (s *BlockStore) SetBlock(b Block) error {
+// SetBlock stores b along with its sideband, of which the ledger
+// provides the account and balance. The height and timestamp are
+// filled in, and b is recorded as the successor of its previous.
+func (s *BlockStore) SetBlock(b Block, sb *Sideband) error {
 	if !ValidateBlockWork(b) {
-		return errors.New("invalid block work")
+		return ErrInvalidWork
 	}
 
-	if b.Type() != Open && b.Type() != Change && b.Type() != Send && b.Type() != Receive {
-		return errors.New("unknown block type")
+	if b.Type() != Open && b.Type() != Change && b.Type() != Send && b.Type() != Receive && b.Type() != Utx {
+		return ErrUnknownType
 	}
 
-	_, err := s.GetBlock(b.GetPrevious())
-	if err != nil {
-		if err == badger.ErrKeyNotFound {
-			if _, ok := s.orphanBlocks[b.GetPrevious()]; !ok && b.Hash() != GenesisBlock.Hash() {
-				s.orphanBlocks[b.GetPrevious()] = b
-				log.WithFields(log.Fields{
-					"hash":     b.Hash().String(),
-					"previous": b.GetPrevious().String(),
-				}).Info("Added orphan block")
-				return errors.New("cannot find parent block")
+	if err := s.DetectFork(b); err != nil {
+		return err
+	}
+
+	if !b.GetPrevious().IsZero() && b.Hash() != GenesisBlock.Hash() {
+		// Pruned blocks, which open blocks may receive, count as present
+		if _, err := s.GetBlock(b.GetPrevious()); err != nil && err != ErrPruned {
+			if err == store.ErrKeyNotFound {
+				return ErrMissingPrevious
 			}
-		} else {
+
+			return err
+		}
+	}
+
+	res := *sb
+	res.Successor = types.BlockHash{}
+	res.Height = 1
+	if res.Timestamp == 0 {
+		res.Timestamp = time.Now().Unix()
+	}
+
+	if previous := chainPrevious(b); !previous.IsZero() {
+		pb, psb, err := s.getBlock(previous)
+		if err != nil {
+			return err
+		}
+
+		res.Height = psb.Height + 1
+		psb.Successor = b.Hash()
+		if err := s.putBlock(pb, psb); err != nil {
 			return err
 		}
 	}
 
-	var buf bytes.Buffer
-	enc := gob.NewEncoder(&buf)
-	if err := enc.Encode(&b); err != nil {
+	if err := s.s.Set(rootKey(b.GetRoot()), b.Hash().Slice()); err != nil {
 		return err
 	}
 
-	// TODO: Store orphan children
-	// TODO: If open, store twice?
-	return s.s.Set(append([]byte("block:"), b.Hash().Slice()...), buf.Bytes())
+	return s.putBlock(b, &res)
 }
 
 func (s *BlockStore) GetBlock(hash types.BlockHash) (Block, error) {
-	v, err := s.s.Get(append([]byte("block:"), hash.Slice()...))
+	b, _, err := s.getBlock(hash)
+	return b, err
+}
+
+// GetSideband returns the sideband stored with the block.
+func (s *BlockStore) GetSideband(hash types.BlockHash) (*Sideband, error) {
+	_, sb, err := s.getBlock(hash)
+	return sb, err
+}
+
+// SetSideband replaces the sideband of a stored block.
+func (s *BlockStore) SetSideband(hash types.BlockHash, sb *Sideband) error {
+	b, _, err := s.getBlock(hash)
 	if err != nil {
-		return nil, err
+		return err
 	}
 
-	buf := bytes.NewBuffer(v)
-	dec := gob.NewDecoder(buf)
+	return s.putBlock(b, sb)
+}
+
+// SetRoot records b as the block succeeding its root, for
+// the blocks stored before their roots were recorded.
+func (s *BlockStore) SetRoot(b Block) error {
+	return s.s.Set(rootKey(b.GetRoot()), b.Hash().Slice())
+}
+
+// DeleteBlock removes the block with the given hash, and frees
+// its root for another block if it was the one succeeding it.
+func (s *BlockStore) DeleteBlock(hash types.BlockHash) error {
+	b, err := s.GetBlock(hash)
+	if err != nil {
+		return err
+	}
 
-	var b Block
-	if err = dec.Decode(&b); err != nil {
-		return nil, err
+	v, err := s.s.Get(rootKey(b.GetRoot()))
+	if err != nil && err != store.ErrKeyNotFound {
+		return err
 	}
 
-	return b, nil
+	if err == nil && types.BlockHashFromSlice(v) == hash {
+		if err := s.s.Delete(rootKey(b.GetRoot())); err != nil {
+			return err
+		}
+	}
+
+	if previous := chainPrevious(b); !previous.IsZero() {
+		pb, psb, err := s.getBlock(previous)
+		if err != nil && err != store.ErrKeyNotFound {
+			return err
+		}
+
+		if err == nil && psb.Successor == hash {
+			psb.Successor = types.BlockHash{}
+			if err := s.putBlock(pb, psb); err != nil {
+				return err
+			}
+		}
+	}
+
+	return s.s.Delete(blockKey(hash))
+}
+
+func (s *BlockStore) getBlock(hash types.BlockHash) (Block, *Sideband, error) {
+	v, err := s.s.Get(blockKey(hash))
+	if err != nil {
+		if err == store.ErrKeyNotFound {
+			pruned, perr := s.pruned(hash)
+			if perr != nil {
+				return nil, nil, perr
+			}
+
+			if pruned {
+				return nil, nil, ErrPruned
+			}
+		}
+
+		return nil, nil, err
+	}
+
+	return decodeBlock(v)
+}
+
+func (s *BlockStore) putBlock(b Block, sb *Sideband) error {
+	v, err := encodeBlock(b, sb)
+	if err != nil {
+		return err
+	}
+
+	return s.s.Set(blockKey(b.Hash()), v)
+}
+
+// chainPrevious returns the previous block in the chain of b,
+// which is zero for open blocks.
+func chainPrevious(b Block) types.BlockHash {
+	if b.Type() == Open {
+		return types.BlockHash{}
+	}
+
+	return b.GetPrevious()
+}
+
+func blockKey(hash types.BlockHash) []byte {
+	return append([]byte("block:"), hash.Slice()...)
 }
@@ -1,8 +1,11 @@
 package blocks
 
 import (
+	"bytes"
+	"encoding/gob"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/s1na/nano/store"
 
@@ -17,7 +20,7 @@ func TestSetGetGenesis(t *testing.T) {
 	s.Start()
 	bs := NewBlockStore(s)
 
-	err := bs.SetBlock(GenesisBlock)
+	err := bs.SetBlock(GenesisBlock, &Sideband{Account: GenesisBlock.Account, Balance: GenesisAmount})
 	require.Nil(t, err)
 
 	b, err := bs.GetBlock(GenesisBlock.Hash())
@@ -26,6 +29,71 @@ func TestSetGetGenesis(t *testing.T) {
 	ob := b.(*OpenBlock)
 	assert.Equal(t, GenesisBlock, ob)
 
+	sb, err := bs.GetSideband(GenesisBlock.Hash())
+	require.Nil(t, err)
+	assert.Equal(t, GenesisBlock.Account, sb.Account)
+	assert.Equal(t, GenesisAmount, sb.Balance)
+	assert.Equal(t, uint64(1), sb.Height)
+	assert.NotZero(t, sb.Timestamp)
+
 	s.Stop()
 	os.RemoveAll("testdata")
 }
+
+func TestUnchecked(t *testing.T) {
+	GenesisBlock = TestGenesisBlock
+
+	s := store.NewStore("testdata")
+	s.Start()
+	bs := NewBlockStore(s)
+
+	dep := GenesisBlock.Source
+	err := bs.AddUnchecked(dep, GenesisBlock)
+	require.Nil(t, err)
+
+	// Adding the same block twice is a no-op
+	err = bs.AddUnchecked(dep, GenesisBlock)
+	require.Nil(t, err)
+
+	unchecked, err := bs.GetUnchecked(dep)
+	require.Nil(t, err)
+	require.Len(t, unchecked, 1)
+	assert.Equal(t, GenesisBlock.Hash(), unchecked[0].Hash())
+
+	count, err := bs.CountUnchecked()
+	require.Nil(t, err)
+	assert.EqualValues(t, 1, count)
+
+	pruned, err := bs.PruneUnchecked(time.Hour)
+	require.Nil(t, err)
+	assert.Equal(t, 0, pruned)
+
+	pruned, err = bs.PruneUnchecked(-time.Hour)
+	require.Nil(t, err)
+	assert.Equal(t, 1, pruned)
+
+	count, err = bs.CountUnchecked()
+	require.Nil(t, err)
+	assert.EqualValues(t, 0, count)
+
+	s.Stop()
+	os.RemoveAll("testdata")
+}
+
+func TestMigrateGobBlocks(t *testing.T) {
+	s := store.NewMemoryStore()
+	s.Open()
+	defer s.Stop()
+
+	var b Block = TestGenesisBlock
+	var buf bytes.Buffer
+	require.Nil(t, gob.NewEncoder(&buf).Encode(&b))
+	require.Nil(t, s.Set(append([]byte("block:"), b.Hash().Slice()...), buf.Bytes()))
+
+	require.Nil(t, s.Migrate())
+
+	bs := NewBlockStore(s)
+	res, err := bs.GetBlock(b.Hash())
+	require.Nil(t, err)
+	assert.Equal(t, b, res)
+}
@@ -0,0 +1,164 @@
+package blocks
+
+import (
+	"encoding/binary"
+	"time"
+
+	"github.com/s1na/nano/store"
+	"github.com/s1na/nano/types"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// UncheckedLimit is the maximum number of blocks
+	// kept waiting for their dependencies.
+	UncheckedLimit uint64 = 65536
+	// UncheckedExpiry is how long a block waits for
+	// its dependencies before being dropped.
+	UncheckedExpiry = 30 * time.Minute
+)
+
+var ErrUncheckedFull = errors.New("unchecked pool is full")
+
+const uncheckedPrefix = "unchecked:"
+
+var uncheckedCountKey = []byte("unchecked_count")
+
+// AddUnchecked stores b until the block it depends on,
+// either its previous or its source, arrives.
+func (s *BlockStore) AddUnchecked(dep types.BlockHash, b Block) error {
+	key := uncheckedKey(dep, b.Hash())
+	if _, err := s.s.Get(key); err == nil {
+		return nil
+	} else if err != store.ErrKeyNotFound {
+		return err
+	}
+
+	count, err := s.CountUnchecked()
+	if err != nil {
+		return err
+	}
+
+	if count >= UncheckedLimit {
+		return ErrUncheckedFull
+	}
+
+	v, err := encodeBlock(b, nil)
+	if err != nil {
+		return err
+	}
+
+	ts := make([]byte, 8)
+	binary.BigEndian.PutUint64(ts, uint64(time.Now().Unix()))
+	if err := s.s.Set(key, append(ts, v...)); err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{
+		"hash":       b.Hash().String(),
+		"dependency": dep.String(),
+	}).Info("Added unchecked block")
+
+	return s.setUncheckedCount(count + 1)
+}
+
+// GetUnchecked returns the blocks waiting for dep.
+func (s *BlockStore) GetUnchecked(dep types.BlockHash) ([]Block, error) {
+	res, err := s.s.GetPrefixValues(uncheckedDepKey(dep))
+	if err != nil {
+		return nil, err
+	}
+
+	bs := make([]Block, 0, len(res))
+	for _, v := range res {
+		b, _, err := decodeBlock(v[8:])
+		if err != nil {
+			return nil, err
+		}
+
+		bs = append(bs, b)
+	}
+
+	return bs, nil
+}
+
+func (s *BlockStore) DeleteUnchecked(dep types.BlockHash, hash types.BlockHash) error {
+	key := uncheckedKey(dep, hash)
+	if _, err := s.s.Get(key); err != nil {
+		if err == store.ErrKeyNotFound {
+			return nil
+		}
+
+		return err
+	}
+
+	if err := s.s.Delete(key); err != nil {
+		return err
+	}
+
+	count, err := s.CountUnchecked()
+	if err != nil {
+		return err
+	}
+
+	return s.setUncheckedCount(count - 1)
+}
+
+// PruneUnchecked drops blocks which have been waiting for
+// their dependencies longer than maxAge, and returns
+// how many were dropped.
+func (s *BlockStore) PruneUnchecked(maxAge time.Duration) (int, error) {
+	res, err := s.s.GetPrefixValues([]byte(uncheckedPrefix))
+	if err != nil {
+		return 0, err
+	}
+
+	cutoff := uint64(time.Now().Add(-maxAge).Unix())
+	pruned := 0
+	for k, v := range res {
+		if binary.BigEndian.Uint64(v[:8]) >= cutoff {
+			continue
+		}
+
+		if err := s.s.Delete([]byte(k)); err != nil {
+			return pruned, err
+		}
+		pruned++
+	}
+
+	if pruned == 0 {
+		return 0, nil
+	}
+
+	return pruned, s.setUncheckedCount(uint64(len(res) - pruned))
+}
+
+func (s *BlockStore) CountUnchecked() (uint64, error) {
+	v, err := s.s.Get(uncheckedCountKey)
+	if err != nil {
+		if err == store.ErrKeyNotFound {
+			return 0, nil
+		}
+
+		return 0, err
+	}
+
+	return binary.BigEndian.Uint64(v), nil
+}
+
+func (s *BlockStore) setUncheckedCount(count uint64) error {
+	v := make([]byte, 8)
+	binary.BigEndian.PutUint64(v, count)
+
+	return s.s.Set(uncheckedCountKey, v)
+}
+
+func uncheckedDepKey(dep types.BlockHash) []byte {
+	return append([]byte(uncheckedPrefix), dep.Slice()...)
+}
+
+func uncheckedKey(dep types.BlockHash, hash types.BlockHash) []byte {
+	return append(uncheckedDepKey(dep), hash.Slice()...)
+}
@@ -3,23 +3,28 @@ package blocks
 import (
 	"github.com/s1na/nano/types"
 	"github.com/s1na/nano/types/uint128"
-
-	"github.com/frankh/crypto/ed25519"
 )
 
+// utxPreamble is hashed in front of universal blocks, so
+// that they can never collide with the legacy block types.
+var utxPreamble = [32]byte{31: 6}
+
+// UtxBlock is a universal (state) block, which states the
+// full state of its account chain. Whether it sends, receives
+// or only changes representative is derived from the balance
+// of the previous block, and the link field holds either the
+// destination account, or the source block hash.
 type UtxBlock struct {
 	Account        types.PubKey
 	Previous       types.BlockHash
 	Representative types.PubKey
 	Balance        uint128.Uint128
-	Amount         uint128.Uint128
 	Link           types.PubKey
 	CommonBlock
 }
 
 func (b *UtxBlock) Hash() types.BlockHash {
-	// TODO: Add type as uint256 preamble
-	return HashUtx(b.Account, b.Previous, b.Representative, b.Balance, b.Amount, b.Link)
+	return HashUtx(b.Account, b.Previous, b.Representative, b.Balance, b.Link)
 }
 
 func (b *UtxBlock) GetPrevious() types.BlockHash {
@@ -40,14 +45,31 @@ func (b *UtxBlock) Type() BlockType {
 }
 
 func (b *UtxBlock) VerifySignature() (bool, error) {
-	return ed25519.Verify(ed25519.PublicKey(b.Account), b.Hash().Slice(), b.Signature[:]), nil
+	return VerifySignature(b, b.Signer()) == nil, nil
+}
+
+// IsOpen reports whether the block is the first one in its chain.
+func (b *UtxBlock) IsOpen() bool {
+	return b.Previous.IsZero()
+}
+
+// IsSend reports whether the block sends funds, given
+// the balance of the account before the block.
+func (b *UtxBlock) IsSend(previous uint128.Uint128) bool {
+	return b.Balance.Compare(previous) < 0
+}
+
+// IsReceive reports whether the block receives funds, given
+// the balance of the account before the block.
+func (b *UtxBlock) IsReceive(previous uint128.Uint128) bool {
+	return b.Balance.Compare(previous) > 0
 }
 
-func (b *UtxBlock) IsSend() bool {
-	// TODO: (amount.bytes [0] & 0x80) == 0x80;
-	return b.Amount.Hi != 0 && b.Amount.Lo != 0
+// LinkHash interprets the link field as the source block hash.
+func (b *UtxBlock) LinkHash() types.BlockHash {
+	return types.BlockHashFromSlice(b.Link)
 }
 
-func HashUtx(account types.PubKey, prev types.BlockHash, repr types.PubKey, balance uint128.Uint128, amount uint128.Uint128, link types.PubKey) types.BlockHash {
-	return HashBytes(account, prev[:], repr, balance.GetBytes(), amount.GetBytes(), link)
+func HashUtx(account types.PubKey, prev types.BlockHash, repr types.PubKey, balance uint128.Uint128, link types.PubKey) types.BlockHash {
+	return HashBytes(utxPreamble[:], account, prev[:], repr, balance.GetBytes(), link)
 }
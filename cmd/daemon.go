@@ -14,12 +14,16 @@ import (
 var (
 	InitialPeer string
 	Verbose     bool
+	Backend     string
+	Pruning     bool
 )
 
 func init() {
 	rootCmd.AddCommand(daemonCmd)
-	daemonCmd.Flags().StringVarP(&InitialPeer, "peer", "p", "::ffff:192.168.0.70", "Initial peer to make contact with")
+	daemonCmd.Flags().StringVarP(&InitialPeer, "peer", "p", "", "Initial peer to make contact with, instead of the bootstrap peers of the network")
 	daemonCmd.Flags().BoolVarP(&Verbose, "verbose", "v", false, "Verbose mode")
+	daemonCmd.Flags().StringVarP(&Backend, "backend", "b", config.BadgerBackend, "Storage backend, either badger or memory")
+	daemonCmd.Flags().BoolVar(&Pruning, "pruning", false, "Discard confirmed blocks which are no longer needed")
 }
 
 var daemonCmd = &cobra.Command{
@@ -33,19 +37,27 @@ var daemonCmd = &cobra.Command{
 
 		conf := &config.Config{
 			DataDir: DataDir,
+			Network: Network,
+			Backend: Backend,
+			Pruning: Pruning,
 		}
-		if TestNet {
-			log.Info("Using test network configuration")
-			conf.TestNet = true
-		}
+		log.WithFields(log.Fields{"network": Network.Name}).Info("Using network configuration")
 
 		n := node.NewNode(conf)
-		initialPeer := network.Peer{
-			net.ParseIP(InitialPeer),
-			7075,
+		hosts := Network.BootstrapPeers
+		if InitialPeer != "" {
+			hosts = []string{InitialPeer}
+		}
+
+		for _, host := range hosts {
+			ips, err := net.LookupIP(host)
+			if err != nil || len(ips) == 0 {
+				log.WithFields(log.Fields{"peer": host}).Warn("Failed resolving bootstrap peer")
+				continue
+			}
+
+			n.Net.AddPeer(network.Peer{ips[0], Network.PeeringPort})
 		}
-		n.Net.PeerList = []network.Peer{initialPeer}
-		n.Net.PeerSet = map[string]bool{initialPeer.String(): true}
 
 		n.Start()
 
@@ -0,0 +1,75 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/s1na/nano/store"
+
+	// Register the migrations of the stored blocks,
+	// accounts, pending entries and wallets.
+	_ "github.com/s1na/nano/ledger"
+	_ "github.com/s1na/nano/wallet"
+
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(dbCmd)
+	dbCmd.AddCommand(dbMigrateCmd)
+	dbCmd.AddCommand(dbVersionCmd)
+}
+
+var dbCmd = &cobra.Command{
+	Use:   "db",
+	Short: "Database management",
+	Long:  `Inspect and upgrade the node's database.`,
+}
+
+var dbMigrateCmd = &cobra.Command{
+	Use:   "migrate",
+	Short: "Migrate the database",
+	Long:  `Migrate the database to the schema version of this node.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s := store.NewStore(DataDir)
+		if err := s.Open(); err != nil {
+			return err
+		}
+		defer s.Stop()
+
+		from, err := s.Version()
+		if err != nil {
+			return err
+		}
+
+		if err := s.Migrate(); err != nil {
+			return err
+		}
+
+		fmt.Printf("Migrated from version %d to %d\n", from, store.SchemaVersion)
+
+		return nil
+	},
+}
+
+var dbVersionCmd = &cobra.Command{
+	Use:   "version",
+	Short: "Display the database version",
+	Long:  `Display the schema version of the database, and the one of this node.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s := store.NewStore(DataDir)
+		if err := s.Open(); err != nil {
+			return err
+		}
+		defer s.Stop()
+
+		version, err := s.Version()
+		if err != nil {
+			return err
+		}
+
+		fmt.Printf("Database version: %d\n", version)
+		fmt.Printf("Node version: %d\n", store.SchemaVersion)
+
+		return nil
+	},
+}
@@ -0,0 +1,126 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/s1na/nano/ledger"
+	"github.com/s1na/nano/store"
+
+	"github.com/pkg/errors"
+	"github.com/spf13/cobra"
+)
+
+var Repair bool
+
+func init() {
+	rootCmd.AddCommand(ledgerCmd)
+	ledgerCmd.AddCommand(ledgerExportCmd)
+	ledgerCmd.AddCommand(ledgerImportCmd)
+	ledgerCmd.AddCommand(ledgerVerifyCmd)
+	ledgerVerifyCmd.Flags().BoolVarP(&Repair, "repair", "r", false, "Rewrite the accounts, sidebands, pending entries and weights from the blocks")
+}
+
+var ledgerCmd = &cobra.Command{
+	Use:   "ledger",
+	Short: "Ledger management",
+	Long:  `Export, import and verify the ledger.`,
+}
+
+var ledgerExportCmd = &cobra.Command{
+	Use:   "export FILE",
+	Short: "Export the ledger",
+	Long:  `Write the accounts, blocks, pending entries and weights of the ledger to a checksummed snapshot file.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s := store.NewStore(DataDir)
+		if err := s.Start(); err != nil {
+			return err
+		}
+		defer s.Stop()
+
+		f, err := os.Create(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		if err := ledger.NewLedger(s).Export(f); err != nil {
+			return err
+		}
+
+		if err := f.Sync(); err != nil {
+			return err
+		}
+
+		fmt.Printf("Exported ledger to %s\n", args[0])
+
+		return nil
+	},
+}
+
+var ledgerImportCmd = &cobra.Command{
+	Use:   "import FILE",
+	Short: "Import a ledger snapshot",
+	Long:  `Validate a snapshot file and load it into an empty ledger.`,
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		f, err := os.Open(args[0])
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		s := store.NewStore(DataDir)
+		if err := s.Start(); err != nil {
+			return err
+		}
+		defer s.Stop()
+
+		if err := ledger.NewLedger(s).Import(f); err != nil {
+			return err
+		}
+
+		fmt.Printf("Imported ledger from %s\n", args[0])
+
+		return nil
+	},
+}
+
+var ledgerVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify the ledger",
+	Long:  `Walk every account chain, and check the blocks along with the accounts, pending entries and weights derived from them.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		s := store.NewStore(DataDir)
+		if err := s.Start(); err != nil {
+			return err
+		}
+		defer s.Stop()
+
+		found, err := ledger.NewLedger(s).Verify(Repair)
+		if err != nil {
+			return err
+		}
+
+		unrepaired := 0
+		for _, d := range found {
+			fmt.Println(d)
+			if !d.Repaired {
+				unrepaired++
+			}
+		}
+
+		if unrepaired > 0 {
+			return errors.Errorf("found %d unrepaired discrepancies", unrepaired)
+		}
+
+		if len(found) > 0 {
+			fmt.Printf("Repaired %d discrepancies\n", len(found))
+		} else {
+			fmt.Println("Ledger is consistent")
+		}
+
+		return nil
+	},
+}
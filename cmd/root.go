@@ -11,25 +11,39 @@ import (
 )
 
 var (
-	DataDir string
-	TestNet bool
+	DataDir     string
+	TestNet     bool
+	NetworkName string
+	GenesisKey  string
+	// Network is the profile of the network chosen by the flags.
+	Network *config.Network
 )
 
 func init() {
 	rootCmd.PersistentFlags().StringVarP(&DataDir, "data-dir", "d", "", "Directory to put generated files, e.g. db.")
-	rootCmd.PersistentFlags().BoolVarP(&TestNet, "testnet", "t", false, "Use test network configuration")
+	rootCmd.PersistentFlags().BoolVarP(&TestNet, "testnet", "t", false, "Use test network configuration, same as --network test")
+	rootCmd.PersistentFlags().StringVarP(&NetworkName, "network", "n", config.LiveNetwork, "Network to use, either live, test or dev")
+	rootCmd.PersistentFlags().StringVar(&GenesisKey, "genesis-key", "", "Private key of the genesis account of the dev network")
 }
 
 var rootCmd = &cobra.Command{
 	Use:   "nanode",
 	Short: "Nanode is a Go-based Nano node",
 	Long:  `Nanode is a Go-based Nano node`,
-	PersistentPreRun: func(cmd *cobra.Command, args []string) {
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 		if TestNet {
-			DataDir = path.Join(DataDir, config.TestDBName)
-		} else {
-			DataDir = path.Join(DataDir, config.DBName)
+			NetworkName = config.TestNetwork
 		}
+
+		var err error
+		if Network, err = config.GetNetwork(NetworkName, GenesisKey); err != nil {
+			return err
+		}
+		Network.Apply()
+
+		DataDir = path.Join(DataDir, Network.DBName)
+
+		return nil
 	},
 }
 
@@ -7,9 +7,23 @@ var (
 const (
 	DBName     = "data"
 	TestDBName = "testdata"
+	DevDBName  = "devdata"
+)
+
+// Storage backends the node can keep its data in.
+const (
+	BadgerBackend = "badger"
+	MemoryBackend = "memory"
 )
 
 type Config struct {
 	DataDir string
-	TestNet bool
+	// Network is the profile of the network
+	// to join, the live one if nil.
+	Network *Network
+	// Backend is the storage backend, badger if empty.
+	Backend string
+	// Pruning discards the blocks below the
+	// confirmation height of each account.
+	Pruning bool
 }
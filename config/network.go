@@ -0,0 +1,148 @@
+package config
+
+import (
+	"github.com/s1na/nano/blocks"
+	"github.com/s1na/nano/ledger"
+	"github.com/s1na/nano/network"
+	"github.com/s1na/nano/types"
+	"github.com/s1na/nano/types/uint128"
+
+	"github.com/pkg/errors"
+)
+
+// Networks the node can join.
+const (
+	LiveNetwork = "live"
+	TestNetwork = "test"
+	DevNetwork  = "dev"
+)
+
+var ErrUnknownNetwork = errors.New("unknown network")
+
+// Network is a profile of the parameters
+// which tell networks apart.
+type Network struct {
+	Name          string
+	Genesis       *blocks.OpenBlock
+	GenesisAmount uint128.Uint128
+	// EpochSigner signs the epoch blocks upgrading
+	// account chains, the genesis account if nil.
+	EpochSigner types.PubKey
+	// OnlineWeightMinimum is the least weight voting
+	// takes to confirm blocks, whatever the online weight.
+	OnlineWeightMinimum uint128.Uint128
+	// Magic starts every message, for peers to
+	// drop the ones of other networks.
+	Magic          [2]byte
+	WorkThreshold  uint64
+	PeeringPort    uint16
+	RPCPort        uint16
+	BootstrapPeers []string
+	// DBName is the directory the data of
+	// the network is kept in.
+	DBName string
+}
+
+var Live = &Network{
+	Name:                LiveNetwork,
+	Genesis:             blocks.LiveGenesisBlock,
+	GenesisAmount:       blocks.GenesisAmount,
+	OnlineWeightMinimum: ledger.DefaultOnlineWeightMinimum,
+	Magic:               [2]byte{'R', 'C'},
+	WorkThreshold:       0xffffffc000000000,
+	PeeringPort:         7075,
+	RPCPort:             7076,
+	BootstrapPeers:      []string{"rai.raiblocks.net"},
+	DBName:              DBName,
+}
+
+var Test = &Network{
+	Name:                TestNetwork,
+	Genesis:             blocks.TestGenesisBlock,
+	GenesisAmount:       blocks.GenesisAmount,
+	OnlineWeightMinimum: ledger.DefaultOnlineWeightMinimum,
+	Magic:               [2]byte{'R', 'A'},
+	WorkThreshold:       0xff00000000000000,
+	PeeringPort:         54000,
+	RPCPort:             55000,
+	DBName:              TestDBName,
+}
+
+// NewDevNetwork returns the profile of a private network
+// whose genesis account is the one of the given key, and
+// owns the whole supply.
+func NewDevNetwork(key types.PrvKey) (*Network, error) {
+	n := &Network{
+		Name:                DevNetwork,
+		GenesisAmount:       blocks.GenesisAmount,
+		OnlineWeightMinimum: ledger.DefaultOnlineWeightMinimum,
+		Magic:               [2]byte{'R', 'D'},
+		WorkThreshold:       0xff00000000000000,
+		PeeringPort:         44000,
+		RPCPort:             45000,
+		DBName:              DevDBName,
+	}
+
+	pub, prv, err := types.KeypairFromPrvKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	genesis := &blocks.OpenBlock{
+		Source:         types.BlockHashFromSlice(pub),
+		Representative: pub,
+		Account:        pub,
+	}
+	genesis.Work = generateWork(genesis.GetRoot(), n.WorkThreshold)
+	genesis.Signature = prv.Sign(genesis.Hash().Slice())
+	n.Genesis = genesis
+
+	return n, nil
+}
+
+// GetNetwork returns the profile of the named network. The
+// dev network needs the private key of its genesis account.
+func GetNetwork(name string, genesisKey string) (*Network, error) {
+	switch name {
+	case "", LiveNetwork:
+		return Live, nil
+	case TestNetwork:
+		return Test, nil
+	case DevNetwork:
+		if genesisKey == "" {
+			return nil, errors.New("dev network needs a genesis key")
+		}
+
+		key, err := types.PrvKeyFromString(genesisKey)
+		if err != nil {
+			return nil, err
+		}
+
+		return NewDevNetwork(key)
+	}
+
+	return nil, ErrUnknownNetwork
+}
+
+// Apply sets the genesis block, epoch signer, online weight minimum,
+// magic number and work threshold the other packages use to the
+// ones of n.
+func (n *Network) Apply() {
+	blocks.GenesisBlock = n.Genesis
+	blocks.GenesisAmount = n.GenesisAmount
+	blocks.EpochSigner = n.EpochSigner
+	if blocks.EpochSigner == nil {
+		blocks.EpochSigner = n.Genesis.Account
+	}
+	ledger.OnlineWeightMinimum = n.OnlineWeightMinimum
+	network.MagicNumber = n.Magic
+	types.WorkThreshold = n.WorkThreshold
+}
+
+func generateWork(hash types.BlockHash, threshold uint64) types.Work {
+	prev := types.WorkThreshold
+	defer func() { types.WorkThreshold = prev }()
+
+	types.WorkThreshold = threshold
+	return types.GenerateWorkForHash(hash)
+}
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"testing"
+
+	"github.com/s1na/nano/blocks"
+	"github.com/s1na/nano/types"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDevNetwork(t *testing.T) {
+	n, err := GetNetwork(DevNetwork, blocks.TestPrivateKey)
+	require.Nil(t, err)
+
+	// The test genesis was generated the same way
+	assert.Equal(t, blocks.TestGenesisBlock.Hash(), n.Genesis.Hash())
+	assert.Nil(t, blocks.VerifySignature(n.Genesis, n.Genesis.Account))
+
+	prev := types.WorkThreshold
+	defer func() { types.WorkThreshold = prev }()
+
+	n.Apply()
+	assert.Equal(t, n.Genesis, blocks.GenesisBlock)
+	assert.True(t, blocks.ValidateBlockWork(n.Genesis))
+
+	_, err = GetNetwork(DevNetwork, "")
+	assert.NotNil(t, err)
+
+	_, err = GetNetwork("beta", "")
+	assert.Equal(t, ErrUnknownNetwork, err)
+}
@@ -0,0 +1,140 @@
+package ledger
+
+import (
+	"github.com/s1na/nano/blocks"
+	"github.com/s1na/nano/types"
+	"github.com/s1na/nano/types/uint128"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+var ErrRollbackConfirmed = errors.New("cannot roll back a confirmed block")
+
+// Confirm cements the block with the given hash, along with the
+// blocks preceding it in its chain and, in turn, the sends they
+// receive, by advancing the confirmation heights of their accounts.
+// The conflict on the root of the block, if any, is dropped.
+func (l *Ledger) Confirm(hash types.BlockHash) error {
+	return l.update(func(l *Ledger) error {
+		if err := l.confirm(hash); err != nil {
+			return err
+		}
+
+		b, err := l.bs.GetBlock(hash)
+		if err != nil {
+			if err == blocks.ErrPruned {
+				return nil
+			}
+
+			return errors.Wrapf(err, "failed to fetch block %s", hash)
+		}
+
+		return l.bs.DeleteConflict(b.GetRoot())
+	})
+}
+
+// Confirmed reports whether the block with the given hash is confirmed.
+func (l *Ledger) Confirmed(hash types.BlockHash) (bool, error) {
+	sb, err := l.bs.GetSideband(hash)
+	if err != nil {
+		// Only confirmed blocks are pruned
+		if err == blocks.ErrPruned {
+			return true, nil
+		}
+
+		return false, err
+	}
+
+	acc, err := l.as.GetAccount(sb.Account)
+	if err != nil {
+		return false, err
+	}
+
+	return sb.Height <= acc.ConfirmationHeight, nil
+}
+
+func (l *Ledger) confirm(hash types.BlockHash) error {
+	sb, err := l.bs.GetSideband(hash)
+	if err != nil {
+		if err == blocks.ErrPruned {
+			return nil
+		}
+
+		return errors.Wrapf(err, "failed to fetch block %s", hash)
+	}
+
+	acc, err := l.as.GetAccount(sb.Account)
+	if err != nil {
+		return errors.Wrapf(err, "failed to fetch account %s", sb.Account.Address())
+	}
+
+	if sb.Height <= acc.ConfirmationHeight {
+		return nil
+	}
+
+	// Confirm the sources of the blocks being cemented first
+	for h, height := hash, sb.Height; height > acc.ConfirmationHeight; height-- {
+		b, err := l.bs.GetBlock(h)
+		if err != nil {
+			return errors.Wrapf(err, "failed to fetch block %s", h)
+		}
+
+		source, err := l.source(b)
+		if err != nil {
+			return err
+		}
+
+		if !source.IsZero() {
+			if err := l.confirm(source); err != nil {
+				return err
+			}
+		}
+
+		h = b.GetPrevious()
+	}
+
+	// Confirming the sources may have cemented this chain too
+	if acc, err = l.as.GetAccount(sb.Account); err != nil {
+		return err
+	}
+
+	if sb.Height <= acc.ConfirmationHeight {
+		return nil
+	}
+
+	acc.ConfirmationHeight = sb.Height
+	if err := l.as.SetAccount(acc); err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{"block": hash, "height": sb.Height, "account": acc.Address()}).Info("Confirmed block")
+
+	return nil
+}
+
+// source returns the hash of the send b receives, if any.
+func (l *Ledger) source(b blocks.Block) (types.BlockHash, error) {
+	switch b := b.(type) {
+	case *blocks.ReceiveBlock:
+		return b.Source, nil
+	case *blocks.OpenBlock:
+		if b.Hash() != blocks.GenesisBlock.Hash() {
+			return b.Source, nil
+		}
+	case *blocks.UtxBlock:
+		var balance uint128.Uint128
+		if !b.IsOpen() {
+			var err error
+			if balance, err = l.balance(b.Previous); err != nil {
+				return types.BlockHash{}, err
+			}
+		}
+
+		if b.IsReceive(balance) {
+			return b.LinkHash(), nil
+		}
+	}
+
+	return types.BlockHash{}, nil
+}
@@ -0,0 +1,197 @@
+package ledger
+
+import (
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/s1na/nano/blocks"
+	"github.com/s1na/nano/store"
+	"github.com/s1na/nano/types"
+	"github.com/s1na/nano/types/uint128"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// DefaultOnlineWeightMinimum is 60 million nano, the
+// minimum online weight of the live network.
+var DefaultOnlineWeightMinimum = uint128.FromInts(0x2d239465031da916, 0x05b947c000000000)
+
+var (
+	// QuorumPercent is the share of the online weight, in
+	// percent, which has to vote for a block to confirm it.
+	QuorumPercent uint64 = 50
+	// OnlineWeightMinimum is the least the online weight is
+	// assumed to be, so that a few representatives can't
+	// confirm blocks on their own.
+	OnlineWeightMinimum = DefaultOnlineWeightMinimum
+	// MaxElections is the most roots votes are kept for at once.
+	// Votes for other roots are ignored while there are as many.
+	MaxElections = 10000
+	// ElectionTimeout is how long the votes for a root are kept
+	// after the last one, unless a block is confirmed before.
+	ElectionTimeout = 5 * time.Minute
+)
+
+type vote struct {
+	hash     types.BlockHash
+	sequence uint64
+}
+
+// elections holds the latest vote of each representative
+// for the blocks competing for a root, which are only kept
+// in memory until one of them is confirmed, or they expire.
+type elections struct {
+	mu    sync.Mutex
+	votes map[types.BlockHash]*election
+	// swept is when the expired elections were last dropped.
+	swept time.Time
+}
+
+type election struct {
+	votes   map[string]vote
+	updated time.Time
+}
+
+func newElections() *elections {
+	e := new(elections)
+
+	e.votes = make(map[types.BlockHash]*election)
+	e.swept = time.Now()
+
+	return e
+}
+
+// get returns the election for root, starting it unless there
+// are MaxElections already, in which case it returns nil.
+func (e *elections) get(root types.BlockHash) *election {
+	if el, ok := e.votes[root]; ok {
+		return el
+	}
+
+	if time.Since(e.swept) > ElectionTimeout || len(e.votes) >= MaxElections {
+		e.sweep()
+	}
+
+	if len(e.votes) >= MaxElections {
+		return nil
+	}
+
+	el := &election{votes: make(map[string]vote)}
+	e.votes[root] = el
+
+	return el
+}
+
+// sweep drops the elections which timed out.
+func (e *elections) sweep() {
+	for root, el := range e.votes {
+		if time.Since(el.updated) > ElectionTimeout {
+			delete(e.votes, root)
+		}
+	}
+
+	e.swept = time.Now()
+}
+
+// ProcessVote records the vote of rep for b, replacing its votes with a
+// lower sequence for the same root. Once the votes for a block stored in
+// the ledger outweigh the quorum, it's confirmed, which is reported back.
+func (l *Ledger) ProcessVote(rep types.PubKey, sequence uint64, b blocks.Block) (bool, error) {
+	// Only representatives with some weight are listened to
+	w, err := l.Weight(rep)
+	if err != nil {
+		return false, err
+	}
+
+	if w.Equal(uint128.Uint128{}) {
+		return false, nil
+	}
+
+	l.ObserveRep(rep)
+
+	root := b.GetRoot()
+	l.elections.mu.Lock()
+	defer l.elections.mu.Unlock()
+
+	el := l.elections.get(root)
+	if el == nil {
+		log.WithFields(log.Fields{"root": root}).Debug("Too many elections, ignored vote")
+		return false, nil
+	}
+
+	if v, ok := el.votes[rep.Address()]; ok && v.sequence >= sequence {
+		return false, nil
+	}
+	el.votes[rep.Address()] = vote{b.Hash(), sequence}
+	el.updated = time.Now()
+
+	tally := make(map[types.BlockHash]uint128.Uint128)
+	for addr, v := range el.votes {
+		pub, err := types.PubKeyFromAddress(addr)
+		if err != nil {
+			return false, err
+		}
+
+		w, err := l.Weight(pub)
+		if err != nil {
+			return false, err
+		}
+
+		tally[v.hash] = tally[v.hash].Add(w)
+	}
+
+	online, err := l.OnlineWeight()
+	if err != nil {
+		return false, err
+	}
+
+	q := quorum(online)
+	for hash, w := range tally {
+		if w.Compare(q) <= 0 {
+			continue
+		}
+
+		if _, err := l.bs.GetBlock(hash); err != nil {
+			if err == store.ErrKeyNotFound {
+				log.WithFields(log.Fields{"block": hash, "root": root}).Debug("Block confirmed by votes is not in the ledger")
+				return false, nil
+			}
+
+			// Pruned blocks have been confirmed already
+			if err == blocks.ErrPruned {
+				delete(l.elections.votes, root)
+				return false, nil
+			}
+
+			return false, err
+		}
+
+		if err := l.Confirm(hash); err != nil {
+			return false, err
+		}
+
+		delete(l.elections.votes, root)
+
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// quorum returns the weight votes have to exceed to confirm a block.
+func quorum(online uint128.Uint128) uint128.Uint128 {
+	if online.Compare(OnlineWeightMinimum) < 0 {
+		online = OnlineWeightMinimum
+	}
+
+	q := new(big.Int).SetBytes(online.GetBytes())
+	q.Mul(q, new(big.Int).SetUint64(QuorumPercent))
+	q.Div(q, big.NewInt(100))
+
+	buf := make([]byte, 16)
+	qb := q.Bytes()
+	copy(buf[len(buf)-len(qb):], qb)
+
+	return uint128.FromBytes(buf)
+}
@@ -0,0 +1,94 @@
+package ledger
+
+import (
+	"github.com/s1na/nano/account"
+	"github.com/s1na/nano/blocks"
+	"github.com/s1na/nano/store"
+	"github.com/s1na/nano/types"
+
+	"github.com/pkg/errors"
+)
+
+// frontierPageSize is the most accounts read within one store
+// transaction while walking the frontiers.
+const frontierPageSize = 1000
+
+var errPageFull = errors.New("page full")
+
+// Frontiers calls fn with the head block of every account from
+// start on, ordered by public key, until fn returns an error. The
+// accounts are read in pages, and fn is called between the store
+// transactions, so that it may take its time.
+func (l *Ledger) Frontiers(start types.PubKey, fn func(types.PubKey, types.BlockHash) error) error {
+	for {
+		var pubs []types.PubKey
+		var heads []types.BlockHash
+		err := l.as.IterateFrom(start, func(acc *account.Account) error {
+			if len(pubs) == frontierPageSize {
+				return errPageFull
+			}
+
+			pubs = append(pubs, acc.PublicKey)
+			heads = append(heads, acc.Head)
+			return nil
+		})
+		if err != nil && err != errPageFull {
+			return err
+		}
+
+		for i, pub := range pubs {
+			if err := fn(pub, heads[i]); err != nil {
+				return err
+			}
+		}
+
+		if err == nil {
+			return nil
+		}
+
+		// Seek again right after the last account
+		start = append(append(types.PubKey{}, pubs[len(pubs)-1]...), 0)
+	}
+}
+
+// Chain calls fn with the blocks of the chain of pub, from its head
+// back to end, which isn't included. The whole chain is walked if end
+// is zero or isn't part of it, up to the first pruned block.
+func (l *Ledger) Chain(pub types.PubKey, end types.BlockHash, fn func(blocks.Block) error) error {
+	acc, err := l.as.GetAccount(pub)
+	if err != nil {
+		if err == store.ErrKeyNotFound {
+			return ErrAccountNotFound
+		}
+
+		return err
+	}
+
+	for hash := acc.Head; hash != end; {
+		b, err := l.bs.GetBlock(hash)
+		if err != nil {
+			if err == blocks.ErrPruned {
+				return nil
+			}
+
+			return errors.Wrapf(err, "failed to fetch block %s", hash)
+		}
+
+		sb, err := l.bs.GetSideband(hash)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(b); err != nil {
+			return err
+		}
+
+		if sb.Height == 1 {
+			break
+		}
+
+		hash = b.GetPrevious()
+	}
+
+	return nil
+}
@@ -0,0 +1,201 @@
+package ledger
+
+import (
+	"github.com/s1na/nano/account"
+	"github.com/s1na/nano/blocks"
+	"github.com/s1na/nano/store"
+	"github.com/s1na/nano/types"
+	"github.com/s1na/nano/types/uint128"
+
+	"github.com/pkg/errors"
+)
+
+var ErrNotInChain = errors.New("block is not in the account chain")
+
+// HistoryEntry describes a block of an account chain. Universal
+// blocks are described as the legacy type of what they do.
+type HistoryEntry struct {
+	Type blocks.BlockType
+	// Account is the counterparty, which is the destination
+	// of sends and the sender of receives and opens.
+	Account types.PubKey
+	Amount  uint128.Uint128
+	Hash    types.BlockHash
+	Height  uint64
+}
+
+// HistoryFilter narrows down the account history.
+type HistoryFilter struct {
+	// Reverse walks the chain from the open block
+	// towards the head, instead of backwards.
+	Reverse bool
+	// Types are the entry types to include, all if empty.
+	Types []blocks.BlockType
+}
+
+// AccountHistory walks the chain of pub backwards from head, or from
+// the account head if it's zero, and returns up to count entries
+// after skipping offset entries. A count of zero returns all of them.
+func (l *Ledger) AccountHistory(pub types.PubKey, head types.BlockHash, count int, offset int, filter *HistoryFilter) ([]*HistoryEntry, error) {
+	if filter == nil {
+		filter = new(HistoryFilter)
+	}
+
+	acc, err := l.as.GetAccount(pub)
+	if err != nil {
+		if err == store.ErrKeyNotFound {
+			return nil, ErrAccountNotFound
+		}
+
+		return nil, err
+	}
+
+	hash := head
+	if hash.IsZero() {
+		hash = acc.Head
+		if filter.Reverse {
+			if hash, err = l.oldestBlock(acc); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	entries := make([]*HistoryEntry, 0, count)
+	for !hash.IsZero() && (count <= 0 || len(entries) < count) {
+		b, err := l.bs.GetBlock(hash)
+		if err != nil {
+			if err == blocks.ErrPruned {
+				break
+			}
+
+			return nil, errors.Wrapf(err, "failed to fetch block %s", hash)
+		}
+
+		sb, err := l.bs.GetSideband(hash)
+		if err != nil {
+			return nil, err
+		}
+
+		if !sb.Account.Equal(pub) {
+			return nil, ErrNotInChain
+		}
+
+		e, err := l.historyEntry(b, sb)
+		if errors.Cause(err) == blocks.ErrPruned {
+			// The entry of the oldest block left is unknown once its
+			// previous has been pruned. The history ends at it, or
+			// starts right after it in reverse.
+			if !filter.Reverse {
+				break
+			}
+		} else if err != nil {
+			return nil, err
+		} else if filter.includes(e.Type) {
+			if offset > 0 {
+				offset--
+			} else {
+				entries = append(entries, e)
+			}
+		}
+
+		if filter.Reverse {
+			hash = sb.Successor
+		} else if sb.Height > 1 {
+			hash = b.GetPrevious()
+		} else {
+			break
+		}
+	}
+
+	return entries, nil
+}
+
+// oldestBlock returns the oldest block of the chain of acc which hasn't
+// been pruned. Once the ledger is pruned, it's found walking the chain
+// back from the head, as the blocks after the pruned ones aren't known.
+func (l *Ledger) oldestBlock(acc *account.Account) (types.BlockHash, error) {
+	pruned, err := l.bs.Pruned()
+	if err != nil || !pruned {
+		return acc.Open, err
+	}
+
+	var oldest types.BlockHash
+	err = l.Chain(acc.PublicKey, types.BlockHash{}, func(b blocks.Block) error {
+		oldest = b.Hash()
+		return nil
+	})
+
+	return oldest, err
+}
+
+func (l *Ledger) historyEntry(b blocks.Block, sb *blocks.Sideband) (*HistoryEntry, error) {
+	e := &HistoryEntry{
+		Type:   b.Type(),
+		Hash:   b.Hash(),
+		Height: sb.Height,
+	}
+
+	// The balance before the block
+	var previous uint128.Uint128
+	if sb.Height > 1 {
+		var err error
+		if previous, err = l.balance(b.GetPrevious()); err != nil {
+			return nil, err
+		}
+	}
+
+	var source types.BlockHash
+	switch b := b.(type) {
+	case *blocks.SendBlock:
+		e.Account = b.Destination
+	case *blocks.ReceiveBlock:
+		source = b.Source
+	case *blocks.OpenBlock:
+		if b.Hash() != blocks.GenesisBlock.Hash() {
+			source = b.Source
+		}
+	case *blocks.UtxBlock:
+		switch {
+		case b.IsSend(previous):
+			e.Type = blocks.Send
+			e.Account = b.Link
+		case b.IsReceive(previous):
+			e.Type = blocks.Receive
+			if b.IsOpen() {
+				e.Type = blocks.Open
+			}
+			source = b.LinkHash()
+		default:
+			e.Type = blocks.Change
+		}
+	}
+
+	if !source.IsZero() {
+		var err error
+		if e.Account, err = l.chainAccount(source); err != nil {
+			return nil, err
+		}
+	}
+
+	if sb.Balance.Compare(previous) < 0 {
+		e.Amount = previous.Sub(sb.Balance)
+	} else {
+		e.Amount = sb.Balance.Sub(previous)
+	}
+
+	return e, nil
+}
+
+func (f *HistoryFilter) includes(t blocks.BlockType) bool {
+	if len(f.Types) == 0 {
+		return true
+	}
+
+	for _, ft := range f.Types {
+		if ft == t {
+			return true
+		}
+	}
+
+	return false
+}
@@ -4,31 +4,114 @@ import (
 	"github.com/s1na/nano/account"
 	"github.com/s1na/nano/blocks"
 	"github.com/s1na/nano/store"
+	"github.com/s1na/nano/types"
+	"github.com/s1na/nano/types/uint128"
 
-	"github.com/dgraph-io/badger"
+	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 )
 
+var (
+	ErrAccountNotFound = errors.New("account not found")
+	ErrNotHead         = errors.New("previous block is not the account head")
+	ErrBadSource       = errors.New("source is not a send to this account")
+	ErrNotPending      = errors.New("source is not pending for this account")
+	ErrAccountExists   = errors.New("account has already been opened")
+	ErrBadAmount       = errors.New("received amount doesn't match the pending send")
+	ErrBadLink         = errors.New("link must be empty when balance doesn't change")
+	ErrGapPrevious     = errors.New("previous block is missing")
+	ErrGapSource       = errors.New("source block is missing")
+	ErrBalanceIncrease = errors.New("send balance exceeds account balance")
+	ErrBadEpoch        = errors.New("epoch block changes balance or representative")
+	ErrEpochUpgraded   = errors.New("account has already been upgraded")
+	ErrLegacyBlock     = errors.New("legacy blocks can't follow an epoch block")
+)
+
 type Ledger struct {
 	store *store.Store
-	bs    *blocks.BlockStore
-	as    *account.AccountStore
+	// txn is set for copies of the ledger that
+	// operate within a transaction.
+	txn store.Txn
+	bs  *blocks.BlockStore
+	as  *account.AccountStore
+	ps  *PendingStore
+	ws  *WeightStore
+	// online and elections are shared
+	// by all copies of the ledger.
+	online    *onlineReps
+	elections *elections
 }
 
 func NewLedger(s *store.Store) *Ledger {
 	l := new(Ledger)
 
 	l.store = s
-	l.bs = blocks.NewBlockStore(s)
-	l.as = account.NewAccountStore(s)
+	l.online = newOnlineReps()
+	l.elections = newElections()
+	l.setStores(s)
 
 	return l
 }
 
+func (l *Ledger) setStores(rw store.ReadWriter) {
+	l.bs = blocks.NewBlockStore(rw)
+	l.as = account.NewAccountStore(rw)
+	l.ps = NewPendingStore(rw)
+	l.ws = NewWeightStore(rw)
+}
+
+// update runs fn on a copy of the ledger whose stores all share one
+// transaction, so that its writes are committed or discarded as a unit.
+// Gaps and forks are still committed, as the unchecked block or the
+// conflict recorded for them must persist.
+func (l *Ledger) update(fn func(*Ledger) error) error {
+	if l.txn != nil {
+		return fn(l)
+	}
+
+	var res error
+	err := l.store.Update(func(txn store.Txn) error {
+		res = fn(l.withTxn(txn))
+		if res == ErrGapPrevious || res == ErrGapSource || res == blocks.ErrFork {
+			return nil
+		}
+
+		return res
+	})
+	if err != nil {
+		return err
+	}
+
+	return res
+}
+
+// view runs fn on a copy of the ledger whose stores
+// all read from one consistent transaction.
+func (l *Ledger) view(fn func(*Ledger) error) error {
+	if l.txn != nil {
+		return fn(l)
+	}
+
+	return l.store.View(func(txn store.Txn) error {
+		return fn(l.withTxn(txn))
+	})
+}
+
+func (l *Ledger) withTxn(txn store.Txn) *Ledger {
+	tl := new(Ledger)
+	tl.store = l.store
+	tl.txn = txn
+	tl.online = l.online
+	tl.elections = l.elections
+	tl.setStores(txn)
+
+	return tl
+}
+
 func (l *Ledger) Init() error {
 	_, err := l.bs.GetBlock(blocks.GenesisBlock.Hash())
 	if err != nil {
-		if err == badger.ErrKeyNotFound {
+		if err == store.ErrKeyNotFound {
 			return l.AddOpen(blocks.GenesisBlock)
 		} else {
 			return err
@@ -40,16 +123,46 @@ func (l *Ledger) Init() error {
 }
 
 func (l *Ledger) AddSend(b *blocks.SendBlock) error {
-	if err := l.bs.SetBlock(b); err != nil {
+	return l.update(func(l *Ledger) error {
+		return l.addSend(b)
+	})
+}
+
+func (l *Ledger) addSend(b *blocks.SendBlock) error {
+	acc, err := l.ownerAccount(b.Account, b.Previous)
+	if err != nil {
 		return err
 	}
 
-	acc, err := l.as.GetAccount(b.Account)
-	if err != nil {
-		if err == badger.ErrKeyNotFound {
-			return nil
-		}
+	if err := blocks.VerifySignature(b, acc.PublicKey); err != nil {
+		return err
+	}
+
+	if err := l.checkHead(acc, b); err != nil {
+		return err
+	}
+
+	if acc.Epoch > 0 {
+		return ErrLegacyBlock
+	}
+
+	if b.Balance.Compare(acc.Balance) > 0 {
+		return ErrBalanceIncrease
+	}
 
+	if err := l.bs.SetBlock(b, &blocks.Sideband{Account: acc.PublicKey, Balance: b.Balance}); err != nil {
+		return err
+	}
+
+	p := &Pending{
+		Source: acc.PublicKey,
+		Amount: acc.Balance.Sub(b.Balance),
+	}
+	if err := l.ps.SetPending(b.Destination, b.Hash(), p); err != nil {
+		return err
+	}
+
+	if err := l.moveWeight(acc.Rep, acc.Balance, acc.Rep, b.Balance); err != nil {
 		return err
 	}
 
@@ -64,8 +177,117 @@ func (l *Ledger) AddSend(b *blocks.SendBlock) error {
 	return nil
 }
 
+func (l *Ledger) AddReceive(b *blocks.ReceiveBlock) error {
+	return l.update(func(l *Ledger) error {
+		return l.addReceive(b)
+	})
+}
+
+func (l *Ledger) addReceive(b *blocks.ReceiveBlock) error {
+	acc, err := l.ownerAccount(b.Account, b.Previous)
+	if err != nil {
+		return err
+	}
+
+	if err := blocks.VerifySignature(b, acc.PublicKey); err != nil {
+		return err
+	}
+
+	if err := l.checkHead(acc, b); err != nil {
+		return err
+	}
+
+	if acc.Epoch > 0 {
+		return ErrLegacyBlock
+	}
+
+	p, err := l.pending(acc.PublicKey, b.Source)
+	if err != nil {
+		return err
+	}
+
+	if err := l.bs.SetBlock(b, &blocks.Sideband{Account: acc.PublicKey, Balance: acc.Balance.Add(p.Amount)}); err != nil {
+		return err
+	}
+
+	if err := l.ps.DeletePending(acc.PublicKey, b.Source); err != nil {
+		return err
+	}
+
+	if err := l.ws.AddWeight(acc.Rep, p.Amount); err != nil {
+		return err
+	}
+
+	acc.Balance = acc.Balance.Add(p.Amount)
+	acc.Head = b.Hash()
+	if err = l.as.SetAccount(acc); err != nil {
+		return err
+	}
+
+	log.Printf("Added block %s for account %s\n", b.Hash(), acc.Address())
+
+	return nil
+}
+
+func (l *Ledger) AddChange(b *blocks.ChangeBlock) error {
+	return l.update(func(l *Ledger) error {
+		return l.addChange(b)
+	})
+}
+
+func (l *Ledger) addChange(b *blocks.ChangeBlock) error {
+	acc, err := l.ownerAccount(b.Account, b.Previous)
+	if err != nil {
+		return err
+	}
+
+	if err := blocks.VerifySignature(b, acc.PublicKey); err != nil {
+		return err
+	}
+
+	if err := l.checkHead(acc, b); err != nil {
+		return err
+	}
+
+	if acc.Epoch > 0 {
+		return ErrLegacyBlock
+	}
+
+	if err := l.bs.SetBlock(b, &blocks.Sideband{Account: acc.PublicKey, Balance: acc.Balance}); err != nil {
+		return err
+	}
+
+	if err := l.moveWeight(acc.Rep, acc.Balance, b.Representative, acc.Balance); err != nil {
+		return err
+	}
+
+	acc.Rep = b.Representative
+	acc.Head = b.Hash()
+	if err = l.as.SetAccount(acc); err != nil {
+		return err
+	}
+
+	log.Printf("Added block %s for account %s\n", b.Hash(), acc.Address())
+
+	return nil
+}
+
 func (l *Ledger) AddOpen(b *blocks.OpenBlock) error {
-	if err := l.bs.SetBlock(b); err != nil {
+	return l.update(func(l *Ledger) error {
+		return l.addOpen(b)
+	})
+}
+
+func (l *Ledger) addOpen(b *blocks.OpenBlock) error {
+	if err := blocks.VerifySignature(b, b.Account); err != nil {
+		return err
+	}
+
+	if _, err := l.as.GetAccount(b.Account); err != store.ErrKeyNotFound {
+		if err == nil {
+			return l.accountExists(b)
+		}
+
 		return err
 	}
 
@@ -75,8 +297,30 @@ func (l *Ledger) AddOpen(b *blocks.OpenBlock) error {
 	acc.Head = b.Hash()
 	acc.Open = b.Hash()
 
-	if b.Hash() == blocks.GenesisBlock.Hash() {
+	genesis := b.Hash() == blocks.GenesisBlock.Hash()
+	if genesis {
 		acc.Balance = blocks.GenesisAmount
+	} else {
+		p, err := l.pending(b.Account, b.Source)
+		if err != nil {
+			return err
+		}
+
+		acc.Balance = p.Amount
+	}
+
+	if err := l.bs.SetBlock(b, &blocks.Sideband{Account: acc.PublicKey, Balance: acc.Balance}); err != nil {
+		return err
+	}
+
+	if !genesis {
+		if err := l.ps.DeletePending(b.Account, b.Source); err != nil {
+			return err
+		}
+	}
+
+	if err := l.ws.AddWeight(acc.Rep, acc.Balance); err != nil {
+		return err
 	}
 
 	if err := l.as.SetAccount(acc); err != nil {
@@ -88,13 +332,420 @@ func (l *Ledger) AddOpen(b *blocks.OpenBlock) error {
 	return nil
 }
 
+// AddUtx applies a universal block, deriving whether it
+// opens, sends, receives or changes from the balance delta.
+func (l *Ledger) AddUtx(b *blocks.UtxBlock) error {
+	return l.update(func(l *Ledger) error {
+		return l.addUtx(b)
+	})
+}
+
+func (l *Ledger) addUtx(b *blocks.UtxBlock) error {
+	if err := blocks.VerifySignature(b, b.Signer()); err != nil {
+		return err
+	}
+
+	acc, err := l.as.GetAccount(b.Account)
+	if b.IsOpen() {
+		if err == nil {
+			return l.accountExists(b)
+		} else if err != store.ErrKeyNotFound {
+			return err
+		}
+
+		acc = account.NewAccount()
+		acc.PublicKey = b.Account
+		acc.Open = b.Hash()
+	} else {
+		if err != nil {
+			if err == store.ErrKeyNotFound {
+				return ErrAccountNotFound
+			}
+
+			return err
+		}
+
+		if err := l.checkHead(acc, b); err != nil {
+			return err
+		}
+	}
+
+	sb := &blocks.Sideband{Account: acc.PublicKey, Balance: b.Balance}
+	switch {
+	case b.IsEpoch():
+		if b.IsOpen() || !b.Balance.Equal(acc.Balance) || !b.Representative.Equal(acc.Rep) {
+			return ErrBadEpoch
+		}
+
+		if acc.Epoch >= blocks.Epoch1 {
+			return ErrEpochUpgraded
+		}
+
+		if err := l.bs.SetBlock(b, sb); err != nil {
+			return err
+		}
+
+		acc.Epoch = blocks.Epoch1
+	case b.IsSend(acc.Balance):
+		p := &Pending{
+			Source: acc.PublicKey,
+			Amount: acc.Balance.Sub(b.Balance),
+		}
+
+		if err := l.bs.SetBlock(b, sb); err != nil {
+			return err
+		}
+
+		if err := l.ps.SetPending(b.Link, b.Hash(), p); err != nil {
+			return err
+		}
+	case b.IsReceive(acc.Balance):
+		p, err := l.pending(acc.PublicKey, b.LinkHash())
+		if err != nil {
+			return err
+		}
+
+		if !p.Amount.Equal(b.Balance.Sub(acc.Balance)) {
+			return ErrBadAmount
+		}
+
+		if err := l.bs.SetBlock(b, sb); err != nil {
+			return err
+		}
+
+		if err := l.ps.DeletePending(acc.PublicKey, b.LinkHash()); err != nil {
+			return err
+		}
+	default:
+		// Opening an account requires receiving a send
+		if b.IsOpen() {
+			return ErrNotPending
+		}
+
+		if !b.LinkHash().IsZero() {
+			return ErrBadLink
+		}
+
+		if err := l.bs.SetBlock(b, sb); err != nil {
+			return err
+		}
+	}
+
+	if err := l.moveWeight(acc.Rep, acc.Balance, b.Representative, b.Balance); err != nil {
+		return err
+	}
+
+	acc.Balance = b.Balance
+	acc.Rep = b.Representative
+	acc.Head = b.Hash()
+	if err := l.as.SetAccount(acc); err != nil {
+		return err
+	}
+
+	log.Printf("Added block %s for account %s\n", b.Hash(), acc.Address())
+
+	return nil
+}
+
+// AddBlock applies a block of any type to the ledger. Blocks whose
+// previous or source block is missing are kept in the unchecked pool,
+// and blocks waiting in there are applied once their dependency is.
 func (l *Ledger) AddBlock(block blocks.Block) error {
+	if err := l.update(func(l *Ledger) error { return l.addBlock(block) }); err != nil {
+		return err
+	}
+
+	l.processUnchecked(block.Hash())
+
+	return nil
+}
+
+// AddBlocks applies a batch of blocks within a single store
+// transaction, returning the result of each. Blocks being rejected
+// don't affect the rest of the batch, whereas any other failure
+// aborts it as a whole.
+func (l *Ledger) AddBlocks(bs []blocks.Block) ([]error, error) {
+	results := make([]error, len(bs))
+	err := l.update(func(l *Ledger) error {
+		for i, b := range bs {
+			err := l.addBlock(b)
+			if err != nil && !Rejected(err) {
+				return errors.Wrapf(err, "failed adding block %s", b.Hash())
+			}
+
+			results[i] = err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for i, b := range bs {
+		if results[i] == nil {
+			l.processUnchecked(b.Hash())
+		}
+	}
+
+	return results, nil
+}
+
+// Rejected reports whether err is the result of a block failing
+// validation, as opposed to a failure to access the store.
+func Rejected(err error) bool {
+	switch errors.Cause(err) {
+	case ErrAccountNotFound, ErrNotHead, ErrBadSource, ErrNotPending, ErrAccountExists,
+		ErrBadAmount, ErrBadLink, ErrGapPrevious, ErrGapSource, ErrBalanceIncrease,
+		ErrBadEpoch, ErrEpochUpgraded, ErrLegacyBlock, blocks.ErrFork,
+		blocks.ErrMissingPrevious, blocks.ErrInvalidWork, blocks.ErrUnknownType, blocks.ErrUncheckedFull:
+		return true
+	}
+
+	_, ok := errors.Cause(err).(*blocks.SignatureError)
+	return ok
+}
+
+// PruneUnchecked drops expired blocks from the unchecked pool.
+func (l *Ledger) PruneUnchecked() (int, error) {
+	return l.bs.PruneUnchecked(blocks.UncheckedExpiry)
+}
+
+func (l *Ledger) addBlock(block blocks.Block) error {
+	dep, err := l.missingDependency(block)
+	if err != nil {
+		if err == ErrGapPrevious || err == ErrGapSource {
+			if err := l.bs.AddUnchecked(dep, block); err != nil {
+				return err
+			}
+		}
+
+		return err
+	}
+
 	switch b := block.(type) {
 	case *blocks.SendBlock:
-		return l.AddSend(b)
+		return l.addSend(b)
+	case *blocks.ReceiveBlock:
+		return l.addReceive(b)
+	case *blocks.ChangeBlock:
+		return l.addChange(b)
 	case *blocks.OpenBlock:
-		return l.AddOpen(b)
+		return l.addOpen(b)
+	case *blocks.UtxBlock:
+		return l.addUtx(b)
 	}
 
-	return nil
+	return blocks.ErrUnknownType
+}
+
+// processUnchecked applies the blocks which were waiting for
+// hash, and in turn the ones waiting for those.
+func (l *Ledger) processUnchecked(hash types.BlockHash) {
+	queue := []types.BlockHash{hash}
+	for len(queue) > 0 {
+		dep := queue[0]
+		queue = queue[1:]
+
+		bs, err := l.bs.GetUnchecked(dep)
+		if err != nil {
+			log.WithFields(log.Fields{"dependency": dep, "err": err.Error()}).Warn("Failed fetching unchecked blocks")
+			continue
+		}
+
+		for _, b := range bs {
+			if err := l.bs.DeleteUnchecked(dep, b.Hash()); err != nil {
+				log.WithFields(log.Fields{"block": b.Hash(), "err": err.Error()}).Warn("Failed deleting unchecked block")
+				continue
+			}
+
+			if err := l.update(func(l *Ledger) error { return l.addBlock(b) }); err != nil {
+				log.WithFields(log.Fields{"block": b.Hash(), "err": err.Error()}).Debug("Failed adding unchecked block")
+				continue
+			}
+
+			queue = append(queue, b.Hash())
+		}
+	}
+}
+
+// missingDependency returns the hash of a block b depends on
+// which hasn't been stored yet, along with the matching gap error.
+func (l *Ledger) missingDependency(block blocks.Block) (types.BlockHash, error) {
+	var previous, source types.BlockHash
+
+	switch b := block.(type) {
+	case *blocks.OpenBlock:
+		if b.Hash() != blocks.GenesisBlock.Hash() {
+			source = b.Source
+		}
+	case *blocks.ReceiveBlock:
+		previous, source = b.Previous, b.Source
+	case *blocks.UtxBlock:
+		previous = b.Previous
+		if b.IsOpen() {
+			source = b.LinkHash()
+		} else if acc, err := l.as.GetAccount(b.Account); err == nil && acc.Head == b.Previous && b.IsReceive(acc.Balance) {
+			source = b.LinkHash()
+		}
+	default:
+		previous = b.GetPrevious()
+	}
+
+	for _, dep := range []struct {
+		hash types.BlockHash
+		err  error
+	}{{previous, ErrGapPrevious}, {source, ErrGapSource}} {
+		if dep.hash.IsZero() {
+			continue
+		}
+
+		// Pruned blocks are only missing their contents
+		if _, err := l.bs.GetBlock(dep.hash); err != nil && err != blocks.ErrPruned {
+			if err == store.ErrKeyNotFound {
+				return dep.hash, dep.err
+			}
+
+			return dep.hash, err
+		}
+	}
+
+	return types.BlockHash{}, nil
+}
+
+// HasBlock reports whether the block with the given hash is in the
+// ledger, which pruned blocks still are.
+func (l *Ledger) HasBlock(hash types.BlockHash) (bool, error) {
+	if _, err := l.bs.GetBlock(hash); err != nil {
+		if err == store.ErrKeyNotFound {
+			return false, nil
+		}
+
+		if err != blocks.ErrPruned {
+			return false, err
+		}
+	}
+
+	return true, nil
+}
+
+// Pending returns the sends waiting to be received by
+// the given account, keyed by their block hash.
+func (l *Ledger) Pending(pub types.PubKey) (map[types.BlockHash]*Pending, error) {
+	return l.ps.GetPendings(pub)
+}
+
+func (l *Ledger) GetPending(pub types.PubKey, hash types.BlockHash) (*Pending, error) {
+	return l.ps.GetPending(pub, hash)
+}
+
+func (l *Ledger) pending(pub types.PubKey, hash types.BlockHash) (*Pending, error) {
+	p, err := l.ps.GetPending(pub, hash)
+	if err != nil {
+		if err == store.ErrKeyNotFound {
+			return nil, ErrNotPending
+		}
+
+		return nil, err
+	}
+
+	return p, nil
+}
+
+// Weight returns the voting weight delegated to rep.
+func (l *Ledger) Weight(rep types.PubKey) (uint128.Uint128, error) {
+	return l.ws.GetWeight(rep)
+}
+
+// Weights returns the voting weight of all
+// representatives, keyed by their address.
+func (l *Ledger) Weights() (map[string]uint128.Uint128, error) {
+	return l.ws.GetWeights()
+}
+
+// moveWeight moves the balance of an account from the weight of
+// its previous representative to the one of its new representative.
+// Accounts being opened have no previous representative, and ones
+// being rolled back past their open block no new one.
+func (l *Ledger) moveWeight(oldRep types.PubKey, oldBalance uint128.Uint128, newRep types.PubKey, newBalance uint128.Uint128) error {
+	if len(oldRep) != 0 {
+		if err := l.ws.SubWeight(oldRep, oldBalance); err != nil {
+			return err
+		}
+	}
+
+	if len(newRep) == 0 {
+		return nil
+	}
+
+	return l.ws.AddWeight(newRep, newBalance)
+}
+
+// Forks returns the competing blocks of all active
+// forks, keyed by the root they compete for.
+func (l *Ledger) Forks() (map[types.BlockHash][]blocks.Block, error) {
+	return l.bs.GetForks()
+}
+
+// checkHead makes sure b extends the head of acc. Otherwise,
+// if another block already succeeds the same root, both are
+// recorded as a fork.
+func (l *Ledger) checkHead(acc *account.Account, b blocks.Block) error {
+	if acc.Head == b.GetPrevious() {
+		return nil
+	}
+
+	if err := l.bs.DetectFork(b); err != nil {
+		return err
+	}
+
+	return ErrNotHead
+}
+
+// accountExists is the error for opening an already opened
+// account, which is a fork if the open blocks differ.
+func (l *Ledger) accountExists(b blocks.Block) error {
+	if err := l.bs.DetectFork(b); err != nil {
+		return err
+	}
+
+	return ErrAccountExists
+}
+
+// ownerAccount returns the account whose chain the block with the
+// given previous hash extends. Blocks received from the network
+// don't carry their account, in which case it's resolved from
+// the chain.
+func (l *Ledger) ownerAccount(pub types.PubKey, previous types.BlockHash) (*account.Account, error) {
+	if len(pub) == 0 {
+		var err error
+		if pub, err = l.chainAccount(previous); err != nil {
+			return nil, err
+		}
+	}
+
+	acc, err := l.as.GetAccount(pub)
+	if err != nil {
+		if err == store.ErrKeyNotFound {
+			return nil, ErrAccountNotFound
+		}
+
+		return nil, err
+	}
+
+	return acc, nil
+}
+
+// chainAccount returns the account owning the block with the given hash.
+func (l *Ledger) chainAccount(hash types.BlockHash) (types.PubKey, error) {
+	sb, err := l.bs.GetSideband(hash)
+	if err != nil {
+		if err == blocks.ErrPruned {
+			return l.bs.PrunedAccount(hash)
+		}
+
+		return nil, errors.Wrapf(err, "failed to fetch block %s", hash)
+	}
+
+	return sb.Account, nil
 }
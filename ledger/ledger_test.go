@@ -1,8 +1,9 @@
 package ledger
 
 import (
-	"os"
+	"bytes"
 	"testing"
+	"time"
 
 	"github.com/s1na/nano/account"
 	"github.com/s1na/nano/blocks"
@@ -10,6 +11,7 @@ import (
 	"github.com/s1na/nano/types"
 	"github.com/s1na/nano/types/uint128"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 	"github.com/stretchr/testify/suite"
 )
@@ -19,21 +21,28 @@ type LedgerTestSuite struct {
 	st *store.Store
 	bs *blocks.BlockStore
 	as *account.AccountStore
+	// Private key of the test genesis account
+	key types.PrvKey
 }
 
 func (s *LedgerTestSuite) SetupTest() {
 	blocks.GenesisBlock = blocks.TestGenesisBlock
+	blocks.EpochSigner = blocks.TestGenesisBlock.Account
 	types.WorkThreshold = uint64(0xff00000000000000)
 
-	s.st = store.NewStore("testdata")
+	s.st = store.NewMemoryStore()
 	s.st.Start()
 	s.bs = blocks.NewBlockStore(s.st)
 	s.as = account.NewAccountStore(s.st)
+
+	key, err := types.PrvKeyFromString(blocks.TestPrivateKey)
+	require.Nil(s.T(), err)
+	_, s.key, err = types.KeypairFromPrvKey(key)
+	require.Nil(s.T(), err)
 }
 
 func (s *LedgerTestSuite) TearDownTest() {
 	s.st.Stop()
-	os.RemoveAll("testdata")
 }
 
 func (s *LedgerTestSuite) TestInit() {
@@ -67,6 +76,7 @@ func (s *LedgerTestSuite) TestAddSend() {
 		Balance:     blocks.GenesisAmount.Sub(uint128.FromInts(0, 1000)),
 	}
 	b.Work = types.GenerateWorkForHash(b.GetRoot())
+	b.Signature = s.key.Sign(b.Hash().Slice())
 	err = l.AddSend(b)
 	require.Nil(s.T(), err)
 
@@ -78,6 +88,1520 @@ func (s *LedgerTestSuite) TestAddSend() {
 	s.Equal(b, sb)
 }
 
+func (s *LedgerTestSuite) TestBadSignature() {
+	l := NewLedger(s.st)
+	err := l.Init()
+	require.Nil(s.T(), err)
+
+	dest, destKey, err := types.GenerateKey(nil)
+	require.Nil(s.T(), err)
+
+	// Sent from the genesis account, but signed by the destination
+	b := &blocks.SendBlock{
+		Previous:    blocks.TestGenesisBlock.Hash(),
+		Destination: dest,
+		Balance:     blocks.GenesisAmount.Sub(uint128.FromInts(0, 1000)),
+	}
+	b.Work = types.GenerateWorkForHash(b.GetRoot())
+	b.Signature = destKey.Sign(b.Hash().Slice())
+	err = l.AddBlock(b)
+	require.IsType(s.T(), &blocks.SignatureError{}, err)
+
+	_, err = s.bs.GetBlock(b.Hash())
+	s.NotNil(err)
+}
+
+func (s *LedgerTestSuite) TestFork() {
+	l := NewLedger(s.st)
+	err := l.Init()
+	require.Nil(s.T(), err)
+
+	competitors := make([]*blocks.SendBlock, 2)
+	for i := range competitors {
+		dest, _, err := types.GenerateKey(nil)
+		require.Nil(s.T(), err)
+
+		b := &blocks.SendBlock{
+			Previous:    blocks.TestGenesisBlock.Hash(),
+			Destination: dest,
+			Balance:     blocks.GenesisAmount.Sub(uint128.FromInts(0, 1000)),
+		}
+		b.Work = types.GenerateWorkForHash(b.GetRoot())
+		b.Signature = s.key.Sign(b.Hash().Slice())
+		competitors[i] = b
+	}
+
+	err = l.AddBlock(competitors[0])
+	require.Nil(s.T(), err)
+
+	err = l.AddBlock(competitors[1])
+	s.Equal(blocks.ErrFork, err)
+
+	forks, err := l.Forks()
+	require.Nil(s.T(), err)
+	require.Len(s.T(), forks, 1)
+	s.Len(forks[blocks.TestGenesisBlock.Hash()], 2)
+
+	// Rolling back a competitor resolves the fork
+	_, err = l.Rollback(competitors[0].Hash())
+	require.Nil(s.T(), err)
+
+	forks, err = l.Forks()
+	require.Nil(s.T(), err)
+	s.Len(forks, 0)
+
+	// So does confirming one
+	require.Nil(s.T(), l.AddBlock(competitors[1]))
+	s.Equal(blocks.ErrFork, l.AddBlock(competitors[0]))
+	require.Nil(s.T(), l.Confirm(competitors[1].Hash()))
+
+	forks, err = l.Forks()
+	require.Nil(s.T(), err)
+	s.Len(forks, 0)
+}
+
+func (s *LedgerTestSuite) TestElections() {
+	l := NewLedger(s.st)
+	err := l.Init()
+	require.Nil(s.T(), err)
+
+	defer func(max int) { MaxElections = max }(MaxElections)
+	MaxElections = 1
+
+	dest, destKey, err := types.GenerateKey(nil)
+	require.Nil(s.T(), err)
+
+	send := &blocks.SendBlock{
+		Previous:    blocks.TestGenesisBlock.Hash(),
+		Destination: dest,
+		Balance:     blocks.GenesisAmount.Sub(uint128.FromInts(0, 1000)),
+	}
+	send.Work = types.GenerateWorkForHash(send.GetRoot())
+	send.Signature = s.key.Sign(send.Hash().Slice())
+	require.Nil(s.T(), l.AddBlock(send))
+
+	open := &blocks.OpenBlock{
+		Source:         send.Hash(),
+		Representative: dest,
+		Account:        dest,
+	}
+	open.Work = types.GenerateWorkForHash(open.GetRoot())
+	open.Signature = destKey.Sign(open.Hash().Slice())
+	require.Nil(s.T(), l.AddBlock(open))
+
+	// Keys without any weight aren't listened to
+	other, _, err := types.GenerateKey(nil)
+	require.Nil(s.T(), err)
+	_, err = l.ProcessVote(other, 1, open)
+	require.Nil(s.T(), err)
+	s.Len(l.elections.votes, 0)
+
+	_, err = l.ProcessVote(dest, 1, open)
+	require.Nil(s.T(), err)
+	s.Len(l.elections.votes, 1)
+
+	// No more elections are started while there are too many
+	_, err = l.ProcessVote(dest, 1, send)
+	require.Nil(s.T(), err)
+	s.Len(l.elections.votes, 1)
+	s.Nil(l.elections.votes[send.GetRoot()])
+
+	// Unless some of them are idle
+	l.elections.votes[open.GetRoot()].updated = time.Now().Add(-2 * ElectionTimeout)
+	_, err = l.ProcessVote(dest, 1, send)
+	require.Nil(s.T(), err)
+	s.Len(l.elections.votes, 1)
+	s.NotNil(l.elections.votes[send.GetRoot()])
+}
+
+func (s *LedgerTestSuite) TestUnchecked() {
+	l := NewLedger(s.st)
+	err := l.Init()
+	require.Nil(s.T(), err)
+
+	dest, destKey, err := types.GenerateKey(nil)
+	require.Nil(s.T(), err)
+
+	send := &blocks.SendBlock{
+		Previous:    blocks.TestGenesisBlock.Hash(),
+		Destination: dest,
+		Balance:     blocks.GenesisAmount.Sub(uint128.FromInts(0, 1000)),
+	}
+	send.Work = types.GenerateWorkForHash(send.GetRoot())
+	send.Signature = s.key.Sign(send.Hash().Slice())
+
+	open := &blocks.OpenBlock{
+		Source:         send.Hash(),
+		Representative: dest,
+		Account:        dest,
+	}
+	open.Work = types.GenerateWorkForHash(open.GetRoot())
+	open.Signature = destKey.Sign(open.Hash().Slice())
+
+	// The open arrives before the send it receives
+	err = l.AddBlock(open)
+	s.Equal(ErrGapSource, err)
+
+	count, err := s.bs.CountUnchecked()
+	require.Nil(s.T(), err)
+	s.EqualValues(1, count)
+
+	err = l.AddBlock(send)
+	require.Nil(s.T(), err)
+
+	acc, err := s.as.GetAccount(dest)
+	require.Nil(s.T(), err)
+	s.Equal(open.Hash(), acc.Head)
+
+	count, err = s.bs.CountUnchecked()
+	require.Nil(s.T(), err)
+	s.EqualValues(0, count)
+}
+
+func (s *LedgerTestSuite) TestAddReceive() {
+	l := NewLedger(s.st)
+	err := l.Init()
+	require.Nil(s.T(), err)
+
+	amount := uint128.FromInts(0, 1000)
+	send := &blocks.SendBlock{
+		Previous:    blocks.TestGenesisBlock.Hash(),
+		Destination: blocks.TestGenesisBlock.Account,
+		Balance:     blocks.GenesisAmount.Sub(amount),
+	}
+	send.Work = types.GenerateWorkForHash(send.GetRoot())
+	send.Signature = s.key.Sign(send.Hash().Slice())
+	err = l.AddSend(send)
+	require.Nil(s.T(), err)
+
+	acc, err := s.as.GetAccount(blocks.TestGenesisBlock.Account)
+	require.Nil(s.T(), err)
+	s.Equal(blocks.GenesisAmount.Sub(amount), acc.Balance)
+
+	b := &blocks.ReceiveBlock{
+		Previous: send.Hash(),
+		Source:   send.Hash(),
+	}
+	b.Work = types.GenerateWorkForHash(b.GetRoot())
+	b.Signature = s.key.Sign(b.Hash().Slice())
+	err = l.AddReceive(b)
+	require.Nil(s.T(), err)
+
+	acc, err = s.as.GetAccount(blocks.TestGenesisBlock.Account)
+	require.Nil(s.T(), err)
+	s.Equal(blocks.GenesisAmount, acc.Balance)
+	s.Equal(b.Hash(), acc.Head)
+
+	// Receiving on a stale head must fail
+	err = l.AddReceive(b)
+	s.Equal(ErrNotHead, err)
+}
+
+func (s *LedgerTestSuite) TestPending() {
+	l := NewLedger(s.st)
+	err := l.Init()
+	require.Nil(s.T(), err)
+
+	dest, destKey, err := types.GenerateKey(nil)
+	require.Nil(s.T(), err)
+
+	amount := uint128.FromInts(0, 1000)
+	send := &blocks.SendBlock{
+		Previous:    blocks.TestGenesisBlock.Hash(),
+		Destination: dest,
+		Balance:     blocks.GenesisAmount.Sub(amount),
+	}
+	send.Work = types.GenerateWorkForHash(send.GetRoot())
+	send.Signature = s.key.Sign(send.Hash().Slice())
+	err = l.AddSend(send)
+	require.Nil(s.T(), err)
+
+	pendings, err := l.Pending(dest)
+	require.Nil(s.T(), err)
+	require.Len(s.T(), pendings, 1)
+	s.Equal(amount, pendings[send.Hash()].Amount)
+	s.EqualValues(blocks.TestGenesisBlock.Account, pendings[send.Hash()].Source)
+
+	open := &blocks.OpenBlock{
+		Source:         send.Hash(),
+		Representative: dest,
+		Account:        dest,
+	}
+	open.Work = types.GenerateWorkForHash(open.GetRoot())
+	open.Signature = destKey.Sign(open.Hash().Slice())
+	err = l.AddOpen(open)
+	require.Nil(s.T(), err)
+
+	acc, err := s.as.GetAccount(dest)
+	require.Nil(s.T(), err)
+	s.Equal(amount, acc.Balance)
+
+	pendings, err = l.Pending(dest)
+	require.Nil(s.T(), err)
+	s.Len(pendings, 0)
+
+	// The send can only be pocketed once
+	err = l.AddOpen(open)
+	s.Equal(ErrAccountExists, err)
+}
+
+func (s *LedgerTestSuite) TestAddUtx() {
+	l := NewLedger(s.st)
+	err := l.Init()
+	require.Nil(s.T(), err)
+
+	dest, destKey, err := types.GenerateKey(nil)
+	require.Nil(s.T(), err)
+
+	amount := uint128.FromInts(0, 1000)
+	send := &blocks.UtxBlock{
+		Account:        blocks.TestGenesisBlock.Account,
+		Previous:       blocks.TestGenesisBlock.Hash(),
+		Representative: blocks.TestGenesisBlock.Representative,
+		Balance:        blocks.GenesisAmount.Sub(amount),
+		Link:           dest,
+	}
+	send.Work = types.GenerateWorkForHash(send.GetRoot())
+	send.Signature = s.key.Sign(send.Hash().Slice())
+	err = l.AddBlock(send)
+	require.Nil(s.T(), err)
+
+	p, err := l.GetPending(dest, send.Hash())
+	require.Nil(s.T(), err)
+	s.Equal(amount, p.Amount)
+
+	open := &blocks.UtxBlock{
+		Account:        dest,
+		Representative: dest,
+		Balance:        amount.Add(uint128.FromInts(0, 1)),
+		Link:           types.PubKey(send.Hash().Slice()),
+	}
+	open.Work = types.GenerateWorkForHash(open.GetRoot())
+	open.Signature = destKey.Sign(open.Hash().Slice())
+	err = l.AddBlock(open)
+	s.Equal(ErrBadAmount, err)
+
+	open.Balance = amount
+	open.Work = types.GenerateWorkForHash(open.GetRoot())
+	open.Signature = destKey.Sign(open.Hash().Slice())
+	err = l.AddBlock(open)
+	require.Nil(s.T(), err)
+
+	acc, err := s.as.GetAccount(dest)
+	require.Nil(s.T(), err)
+	s.Equal(amount, acc.Balance)
+	s.Equal(open.Hash(), acc.Open)
+
+	rep, _, err := types.GenerateKey(nil)
+	require.Nil(s.T(), err)
+
+	change := &blocks.UtxBlock{
+		Account:        dest,
+		Previous:       open.Hash(),
+		Representative: rep,
+		Balance:        amount,
+		Link:           types.PubKeyFromSlice(nil),
+	}
+	change.Work = types.GenerateWorkForHash(change.GetRoot())
+	change.Signature = destKey.Sign(change.Hash().Slice())
+	err = l.AddBlock(change)
+	require.Nil(s.T(), err)
+
+	acc, err = s.as.GetAccount(dest)
+	require.Nil(s.T(), err)
+	s.EqualValues(rep, acc.Rep)
+	s.Equal(change.Hash(), acc.Head)
+}
+
+func (s *LedgerTestSuite) TestAddChange() {
+	l := NewLedger(s.st)
+	err := l.Init()
+	require.Nil(s.T(), err)
+
+	rep, _, err := types.GenerateKey(nil)
+	require.Nil(s.T(), err)
+
+	b := &blocks.ChangeBlock{
+		Previous:       blocks.TestGenesisBlock.Hash(),
+		Representative: rep,
+	}
+	b.Work = types.GenerateWorkForHash(b.GetRoot())
+	b.Signature = s.key.Sign(b.Hash().Slice())
+	err = l.AddBlock(b)
+	require.Nil(s.T(), err)
+
+	acc, err := s.as.GetAccount(blocks.TestGenesisBlock.Account)
+	require.Nil(s.T(), err)
+	s.EqualValues(rep, acc.Rep)
+	s.Equal(b.Hash(), acc.Head)
+	s.Equal(blocks.GenesisAmount, acc.Balance)
+}
+
+func (s *LedgerTestSuite) TestWeights() {
+	l := NewLedger(s.st)
+	err := l.Init()
+	require.Nil(s.T(), err)
+
+	genesisRep := blocks.TestGenesisBlock.Representative
+	w, err := l.Weight(genesisRep)
+	require.Nil(s.T(), err)
+	s.Equal(blocks.GenesisAmount, w)
+
+	dest, destKey, err := types.GenerateKey(nil)
+	require.Nil(s.T(), err)
+	rep, _, err := types.GenerateKey(nil)
+	require.Nil(s.T(), err)
+
+	amount := uint128.FromInts(0, 1000)
+	send := &blocks.SendBlock{
+		Previous:    blocks.TestGenesisBlock.Hash(),
+		Destination: dest,
+		Balance:     blocks.GenesisAmount.Sub(amount),
+	}
+	send.Work = types.GenerateWorkForHash(send.GetRoot())
+	send.Signature = s.key.Sign(send.Hash().Slice())
+	require.Nil(s.T(), l.AddBlock(send))
+
+	// Pending sends don't count for any representative
+	w, err = l.Weight(genesisRep)
+	require.Nil(s.T(), err)
+	s.Equal(blocks.GenesisAmount.Sub(amount), w)
+
+	open := &blocks.OpenBlock{
+		Source:         send.Hash(),
+		Representative: rep,
+		Account:        dest,
+	}
+	open.Work = types.GenerateWorkForHash(open.GetRoot())
+	open.Signature = destKey.Sign(open.Hash().Slice())
+	require.Nil(s.T(), l.AddBlock(open))
+
+	w, err = l.Weight(rep)
+	require.Nil(s.T(), err)
+	s.Equal(amount, w)
+
+	change := &blocks.ChangeBlock{
+		Previous:       send.Hash(),
+		Representative: rep,
+	}
+	change.Work = types.GenerateWorkForHash(change.GetRoot())
+	change.Signature = s.key.Sign(change.Hash().Slice())
+	require.Nil(s.T(), l.AddBlock(change))
+
+	weights, err := l.Weights()
+	require.Nil(s.T(), err)
+	s.Equal(map[string]uint128.Uint128{rep.Address(): blocks.GenesisAmount}, weights)
+
+	online, err := l.OnlineWeight()
+	require.Nil(s.T(), err)
+	s.Equal(uint128.Uint128{}, online)
+
+	l.ObserveRep(rep)
+	online, err = l.OnlineWeight()
+	require.Nil(s.T(), err)
+	s.Equal(blocks.GenesisAmount, online)
+}
+
+func (s *LedgerTestSuite) TestRollback() {
+	l := NewLedger(s.st)
+	err := l.Init()
+	require.Nil(s.T(), err)
+
+	dest, destKey, err := types.GenerateKey(nil)
+	require.Nil(s.T(), err)
+	rep, _, err := types.GenerateKey(nil)
+	require.Nil(s.T(), err)
+
+	amount := uint128.FromInts(0, 1000)
+	send := &blocks.SendBlock{
+		Previous:    blocks.TestGenesisBlock.Hash(),
+		Destination: dest,
+		Balance:     blocks.GenesisAmount.Sub(amount),
+	}
+	send.Work = types.GenerateWorkForHash(send.GetRoot())
+	send.Signature = s.key.Sign(send.Hash().Slice())
+	require.Nil(s.T(), l.AddBlock(send))
+
+	open := &blocks.OpenBlock{
+		Source:         send.Hash(),
+		Representative: rep,
+		Account:        dest,
+	}
+	open.Work = types.GenerateWorkForHash(open.GetRoot())
+	open.Signature = destKey.Sign(open.Hash().Slice())
+	require.Nil(s.T(), l.AddBlock(open))
+
+	back := &blocks.SendBlock{
+		Previous:    open.Hash(),
+		Destination: blocks.TestGenesisBlock.Account,
+		Balance:     uint128.FromInts(0, 400),
+	}
+	back.Work = types.GenerateWorkForHash(back.GetRoot())
+	back.Signature = destKey.Sign(back.Hash().Slice())
+	require.Nil(s.T(), l.AddBlock(back))
+
+	receive := &blocks.ReceiveBlock{
+		Previous: send.Hash(),
+		Source:   back.Hash(),
+	}
+	receive.Work = types.GenerateWorkForHash(receive.GetRoot())
+	receive.Signature = s.key.Sign(receive.Hash().Slice())
+	require.Nil(s.T(), l.AddBlock(receive))
+
+	// Undoing the first send undoes everything built on it
+	removed, err := l.Rollback(send.Hash())
+	require.Nil(s.T(), err)
+	hashes := make([]types.BlockHash, 0, len(removed))
+	for _, b := range removed {
+		hashes = append(hashes, b.Hash())
+	}
+	s.Equal([]types.BlockHash{receive.Hash(), back.Hash(), open.Hash(), send.Hash()}, hashes)
+
+	for _, h := range hashes {
+		_, err := s.bs.GetBlock(h)
+		s.Equal(store.ErrKeyNotFound, err)
+	}
+
+	acc, err := s.as.GetAccount(blocks.TestGenesisBlock.Account)
+	require.Nil(s.T(), err)
+	s.Equal(blocks.TestGenesisBlock.Hash(), acc.Head)
+	s.Equal(blocks.GenesisAmount, acc.Balance)
+
+	_, err = s.as.GetAccount(dest)
+	s.Equal(store.ErrKeyNotFound, err)
+
+	for _, pub := range []types.PubKey{dest, blocks.TestGenesisBlock.Account} {
+		pendings, err := l.Pending(pub)
+		require.Nil(s.T(), err)
+		s.Len(pendings, 0)
+	}
+
+	weights, err := l.Weights()
+	require.Nil(s.T(), err)
+	s.Equal(map[string]uint128.Uint128{blocks.TestGenesisBlock.Representative.Address(): blocks.GenesisAmount}, weights)
+
+	// The root is free for the block to be applied again
+	require.Nil(s.T(), l.AddBlock(send))
+
+	_, err = l.Rollback(blocks.TestGenesisBlock.Hash())
+	s.Equal(ErrRollbackGenesis, err)
+}
+
+func (s *LedgerTestSuite) TestSideband() {
+	l := NewLedger(s.st)
+	err := l.Init()
+	require.Nil(s.T(), err)
+
+	amount := uint128.FromInts(0, 1000)
+	send := &blocks.SendBlock{
+		Previous:    blocks.TestGenesisBlock.Hash(),
+		Destination: blocks.TestGenesisBlock.Account,
+		Balance:     blocks.GenesisAmount.Sub(amount),
+	}
+	send.Work = types.GenerateWorkForHash(send.GetRoot())
+	send.Signature = s.key.Sign(send.Hash().Slice())
+	require.Nil(s.T(), l.AddBlock(send))
+
+	receive := &blocks.ReceiveBlock{
+		Previous: send.Hash(),
+		Source:   send.Hash(),
+	}
+	receive.Work = types.GenerateWorkForHash(receive.GetRoot())
+	receive.Signature = s.key.Sign(receive.Hash().Slice())
+	require.Nil(s.T(), l.AddBlock(receive))
+
+	check := func() {
+		expected := []struct {
+			hash      types.BlockHash
+			successor types.BlockHash
+			balance   uint128.Uint128
+		}{
+			{blocks.TestGenesisBlock.Hash(), send.Hash(), blocks.GenesisAmount},
+			{send.Hash(), receive.Hash(), blocks.GenesisAmount.Sub(amount)},
+			{receive.Hash(), types.BlockHash{}, blocks.GenesisAmount},
+		}
+		for i, e := range expected {
+			sb, err := s.bs.GetSideband(e.hash)
+			require.Nil(s.T(), err)
+			s.EqualValues(blocks.TestGenesisBlock.Account, sb.Account)
+			s.Equal(e.successor, sb.Successor)
+			s.Equal(uint64(i+1), sb.Height)
+			s.Equal(e.balance, sb.Balance)
+		}
+	}
+	check()
+
+	// Sidebands and roots are derived again for blocks stored without them
+	err = s.st.Update(func(txn store.Txn) error {
+		bs := blocks.NewBlockStore(txn)
+		for _, h := range []types.BlockHash{blocks.TestGenesisBlock.Hash(), send.Hash(), receive.Hash()} {
+			if err := bs.SetSideband(h, &blocks.Sideband{}); err != nil {
+				return err
+			}
+		}
+
+		for _, k := range txn.GetPrefixKeys([]byte("root:")) {
+			if err := txn.Delete(k); err != nil {
+				return err
+			}
+		}
+
+		return migrateSidebands(txn, append([]byte("account:"), blocks.TestGenesisBlock.Account...), nil)
+	})
+	require.Nil(s.T(), err)
+	check()
+
+	fork := &blocks.SendBlock{
+		Previous:    blocks.TestGenesisBlock.Hash(),
+		Destination: blocks.TestGenesisBlock.Account,
+		Balance:     blocks.GenesisAmount.Sub(amount.Add(amount)),
+	}
+	fork.Work = types.GenerateWorkForHash(fork.GetRoot())
+	fork.Signature = s.key.Sign(fork.Hash().Slice())
+	s.Equal(blocks.ErrFork, l.AddBlock(fork))
+}
+
+func (s *LedgerTestSuite) TestAccountHistory() {
+	l := NewLedger(s.st)
+	err := l.Init()
+	require.Nil(s.T(), err)
+
+	genesis := blocks.TestGenesisBlock.Account
+	dest, destKey, err := types.GenerateKey(nil)
+	require.Nil(s.T(), err)
+
+	send := &blocks.SendBlock{
+		Previous:    blocks.TestGenesisBlock.Hash(),
+		Destination: dest,
+		Balance:     blocks.GenesisAmount.Sub(uint128.FromInts(0, 1000)),
+	}
+	send.Work = types.GenerateWorkForHash(send.GetRoot())
+	send.Signature = s.key.Sign(send.Hash().Slice())
+	require.Nil(s.T(), l.AddBlock(send))
+
+	open := &blocks.UtxBlock{
+		Account:        dest,
+		Representative: dest,
+		Balance:        uint128.FromInts(0, 1000),
+		Link:           types.PubKey(send.Hash().Slice()),
+	}
+	open.Work = types.GenerateWorkForHash(open.GetRoot())
+	open.Signature = destKey.Sign(open.Hash().Slice())
+	require.Nil(s.T(), l.AddBlock(open))
+
+	back := &blocks.UtxBlock{
+		Account:        dest,
+		Previous:       open.Hash(),
+		Representative: dest,
+		Balance:        uint128.FromInts(0, 600),
+		Link:           genesis,
+	}
+	back.Work = types.GenerateWorkForHash(back.GetRoot())
+	back.Signature = destKey.Sign(back.Hash().Slice())
+	require.Nil(s.T(), l.AddBlock(back))
+
+	receive := &blocks.ReceiveBlock{
+		Previous: send.Hash(),
+		Source:   back.Hash(),
+	}
+	receive.Work = types.GenerateWorkForHash(receive.GetRoot())
+	receive.Signature = s.key.Sign(receive.Hash().Slice())
+	require.Nil(s.T(), l.AddBlock(receive))
+
+	entries, err := l.AccountHistory(genesis, types.BlockHash{}, 0, 0, nil)
+	require.Nil(s.T(), err)
+	require.Len(s.T(), entries, 3)
+	s.Equal(&HistoryEntry{blocks.Receive, dest, uint128.FromInts(0, 400), receive.Hash(), 3}, entries[0])
+	s.Equal(&HistoryEntry{blocks.Send, dest, uint128.FromInts(0, 1000), send.Hash(), 2}, entries[1])
+	s.Equal(blocks.Open, entries[2].Type)
+	s.Equal(blocks.GenesisAmount, entries[2].Amount)
+
+	entries, err = l.AccountHistory(dest, types.BlockHash{}, 0, 0, nil)
+	require.Nil(s.T(), err)
+	require.Len(s.T(), entries, 2)
+	s.Equal(&HistoryEntry{blocks.Send, genesis, uint128.FromInts(0, 400), back.Hash(), 2}, entries[0])
+	s.Equal(&HistoryEntry{blocks.Open, genesis, uint128.FromInts(0, 1000), open.Hash(), 1}, entries[1])
+
+	// Paginated, reversed and filtered
+	entries, err = l.AccountHistory(genesis, types.BlockHash{}, 1, 1, nil)
+	require.Nil(s.T(), err)
+	require.Len(s.T(), entries, 1)
+	s.Equal(send.Hash(), entries[0].Hash)
+
+	entries, err = l.AccountHistory(genesis, types.BlockHash{}, 2, 0, &HistoryFilter{Reverse: true})
+	require.Nil(s.T(), err)
+	require.Len(s.T(), entries, 2)
+	s.Equal(blocks.TestGenesisBlock.Hash(), entries[0].Hash)
+	s.Equal(send.Hash(), entries[1].Hash)
+
+	entries, err = l.AccountHistory(genesis, receive.Hash(), 0, 0, &HistoryFilter{Types: []blocks.BlockType{blocks.Send}})
+	require.Nil(s.T(), err)
+	require.Len(s.T(), entries, 1)
+	s.Equal(send.Hash(), entries[0].Hash)
+
+	_, err = l.AccountHistory(dest, send.Hash(), 0, 0, nil)
+	s.Equal(ErrNotInChain, err)
+}
+
+func (s *LedgerTestSuite) TestConfirm() {
+	l := NewLedger(s.st)
+	err := l.Init()
+	require.Nil(s.T(), err)
+
+	dest, destKey, err := types.GenerateKey(nil)
+	require.Nil(s.T(), err)
+
+	amount := uint128.FromInts(0, 1000)
+	send := &blocks.SendBlock{
+		Previous:    blocks.TestGenesisBlock.Hash(),
+		Destination: dest,
+		Balance:     blocks.GenesisAmount.Sub(amount),
+	}
+	send.Work = types.GenerateWorkForHash(send.GetRoot())
+	send.Signature = s.key.Sign(send.Hash().Slice())
+	require.Nil(s.T(), l.AddBlock(send))
+
+	open := &blocks.OpenBlock{
+		Source:         send.Hash(),
+		Representative: dest,
+		Account:        dest,
+	}
+	open.Work = types.GenerateWorkForHash(open.GetRoot())
+	open.Signature = destKey.Sign(open.Hash().Slice())
+	require.Nil(s.T(), l.AddBlock(open))
+
+	// Votes short of the quorum of the online weight don't confirm
+	l.ObserveRep(blocks.TestGenesisBlock.Representative)
+	confirmed, err := l.ProcessVote(dest, 1, open)
+	require.Nil(s.T(), err)
+	s.False(confirmed)
+
+	confirmed, err = l.ProcessVote(blocks.TestGenesisBlock.Representative, 1, open)
+	require.Nil(s.T(), err)
+	s.True(confirmed)
+
+	// Confirming the open cascades to the send it receives
+	for _, h := range []types.BlockHash{blocks.TestGenesisBlock.Hash(), send.Hash(), open.Hash()} {
+		ok, err := l.Confirmed(h)
+		require.Nil(s.T(), err)
+		s.True(ok)
+	}
+
+	acc, err := s.as.GetAccount(blocks.TestGenesisBlock.Account)
+	require.Nil(s.T(), err)
+	s.Equal(uint64(2), acc.ConfirmationHeight)
+
+	_, err = l.Rollback(send.Hash())
+	s.Equal(ErrRollbackConfirmed, err)
+
+	_, err = s.bs.GetBlock(open.Hash())
+	s.Nil(err)
+}
+
+func (s *LedgerTestSuite) TestSnapshot() {
+	l := NewLedger(s.st)
+	err := l.Init()
+	require.Nil(s.T(), err)
+
+	dest, destKey, err := types.GenerateKey(nil)
+	require.Nil(s.T(), err)
+
+	send := &blocks.SendBlock{
+		Previous:    blocks.TestGenesisBlock.Hash(),
+		Destination: dest,
+		Balance:     blocks.GenesisAmount.Sub(uint128.FromInts(0, 1000)),
+	}
+	send.Work = types.GenerateWorkForHash(send.GetRoot())
+	send.Signature = s.key.Sign(send.Hash().Slice())
+	require.Nil(s.T(), l.AddBlock(send))
+
+	open := &blocks.OpenBlock{
+		Source:         send.Hash(),
+		Representative: dest,
+		Account:        dest,
+	}
+	open.Work = types.GenerateWorkForHash(open.GetRoot())
+	open.Signature = destKey.Sign(open.Hash().Slice())
+	require.Nil(s.T(), l.AddBlock(open))
+
+	back := &blocks.SendBlock{
+		Previous:    open.Hash(),
+		Destination: blocks.TestGenesisBlock.Account,
+		Balance:     uint128.FromInts(0, 400),
+	}
+	back.Work = types.GenerateWorkForHash(back.GetRoot())
+	back.Signature = destKey.Sign(back.Hash().Slice())
+	require.Nil(s.T(), l.AddBlock(back))
+
+	// The genesis chain receives from the chain it opened
+	receive := &blocks.ReceiveBlock{
+		Previous: send.Hash(),
+		Source:   back.Hash(),
+	}
+	receive.Work = types.GenerateWorkForHash(receive.GetRoot())
+	receive.Signature = s.key.Sign(receive.Hash().Slice())
+	require.Nil(s.T(), l.AddBlock(receive))
+
+	unreceived := &blocks.SendBlock{
+		Previous:    back.Hash(),
+		Destination: blocks.TestGenesisBlock.Account,
+		Balance:     uint128.FromInts(0, 100),
+	}
+	unreceived.Work = types.GenerateWorkForHash(unreceived.GetRoot())
+	unreceived.Signature = destKey.Sign(unreceived.Hash().Slice())
+	require.Nil(s.T(), l.AddBlock(unreceived))
+	require.Nil(s.T(), l.Confirm(open.Hash()))
+
+	var buf bytes.Buffer
+	require.Nil(s.T(), l.Export(&buf))
+	snapshot := buf.Bytes()
+
+	st := store.NewMemoryStore()
+	require.Nil(s.T(), st.Start())
+	defer st.Stop()
+
+	il := NewLedger(st)
+	require.Nil(s.T(), il.Import(bytes.NewReader(snapshot)))
+
+	as := account.NewAccountStore(st)
+	for _, pub := range []types.PubKey{blocks.TestGenesisBlock.Account, dest} {
+		want, err := s.as.GetAccount(pub)
+		require.Nil(s.T(), err)
+		got, err := as.GetAccount(pub)
+		require.Nil(s.T(), err)
+		s.Equal(want, got)
+	}
+
+	p, err := il.GetPending(blocks.TestGenesisBlock.Account, unreceived.Hash())
+	require.Nil(s.T(), err)
+	s.Equal(uint128.FromInts(0, 300), p.Amount)
+
+	weights, err := l.Weights()
+	require.Nil(s.T(), err)
+	imported, err := il.Weights()
+	require.Nil(s.T(), err)
+	s.Equal(weights, imported)
+
+	// Snapshots of the same ledger are identical
+	buf.Reset()
+	require.Nil(s.T(), il.Export(&buf))
+	s.Equal(snapshot, buf.Bytes())
+
+	s.Equal(ErrLedgerNotEmpty, il.Import(bytes.NewReader(snapshot)))
+
+	corrupt := append([]byte{}, snapshot...)
+	corrupt[len(corrupt)/2] ^= 0xff
+	s.Equal(ErrSnapshotChecksum, NewLedger(store.NewMemoryStore()).Import(bytes.NewReader(corrupt)))
+}
+
+func (s *LedgerTestSuite) TestSnapshotFailedImport() {
+	l := NewLedger(s.st)
+	err := l.Init()
+	require.Nil(s.T(), err)
+
+	dest, _, err := types.GenerateKey(nil)
+	require.Nil(s.T(), err)
+
+	send := &blocks.SendBlock{
+		Previous:    blocks.TestGenesisBlock.Hash(),
+		Destination: dest,
+		Balance:     blocks.GenesisAmount.Sub(uint128.FromInts(0, 1000)),
+	}
+	send.Work = types.GenerateWorkForHash(send.GetRoot())
+	send.Signature = s.key.Sign(send.Hash().Slice())
+	require.Nil(s.T(), l.AddBlock(send))
+
+	var good bytes.Buffer
+	require.Nil(s.T(), l.Export(&good))
+
+	// Well formed, but the account doesn't match the blocks
+	var bad bytes.Buffer
+	sw, err := newSnapshotWriter(&bad)
+	require.Nil(s.T(), err)
+	for _, b := range []blocks.Block{blocks.TestGenesisBlock, send} {
+		id, _ := blocks.TypeId(b.Type())
+		data, err := blocks.MarshalBinary(b)
+		require.Nil(s.T(), err)
+		require.Nil(s.T(), sw.record(recordBlock, append([]byte{id}, data...)))
+	}
+	acc, err := s.as.GetAccount(blocks.TestGenesisBlock.Account)
+	require.Nil(s.T(), err)
+	acc.Balance = blocks.GenesisAmount
+	require.Nil(s.T(), sw.record(recordAccount, encodeSnapshotAccount(acc)))
+	require.Nil(s.T(), sw.close())
+
+	st := store.NewMemoryStore()
+	require.Nil(s.T(), st.Start())
+	defer st.Stop()
+
+	il := NewLedger(st)
+	require.Nil(s.T(), il.bs.AddUnchecked(send.Hash(), send))
+	err = il.Import(bytes.NewReader(bad.Bytes()))
+	s.Equal(ErrSnapshotMismatch, errors.Cause(err))
+
+	// Nothing is left behind, and the import can be retried
+	exists, err := il.HasBlock(send.Hash())
+	require.Nil(s.T(), err)
+	s.False(exists)
+
+	count, err := il.countAccounts()
+	require.Nil(s.T(), err)
+	s.Equal(1, count)
+
+	unchecked, err := il.bs.CountUnchecked()
+	require.Nil(s.T(), err)
+	s.Equal(uint64(0), unchecked)
+
+	require.Nil(s.T(), il.Import(bytes.NewReader(good.Bytes())))
+
+	exists, err = il.HasBlock(send.Hash())
+	require.Nil(s.T(), err)
+	s.True(exists)
+}
+
+func (s *LedgerTestSuite) TestPrune() {
+	l := NewLedger(s.st)
+	err := l.Init()
+	require.Nil(s.T(), err)
+
+	dest, destKey, err := types.GenerateKey(nil)
+	require.Nil(s.T(), err)
+
+	var sends []*blocks.SendBlock
+	previous := blocks.TestGenesisBlock.Hash()
+	for i := uint64(1); i <= 3; i++ {
+		send := &blocks.SendBlock{
+			Previous:    previous,
+			Destination: dest,
+			Balance:     blocks.GenesisAmount.Sub(uint128.FromInts(0, 1000*i)),
+		}
+		send.Work = types.GenerateWorkForHash(send.GetRoot())
+		send.Signature = s.key.Sign(send.Hash().Slice())
+		require.Nil(s.T(), l.AddBlock(send))
+
+		sends = append(sends, send)
+		previous = send.Hash()
+	}
+
+	require.Nil(s.T(), l.Confirm(sends[1].Hash()))
+
+	pruned, err := l.Prune()
+	require.Nil(s.T(), err)
+	s.Equal(1, pruned)
+
+	// Pruning again has nothing left to do
+	pruned, err = l.Prune()
+	require.Nil(s.T(), err)
+	s.Equal(0, pruned)
+
+	_, err = s.bs.GetBlock(sends[0].Hash())
+	s.Equal(blocks.ErrPruned, err)
+	_, err = s.bs.GetBlock(types.BlockHash{1})
+	s.Equal(store.ErrKeyNotFound, err)
+
+	for _, h := range []types.BlockHash{blocks.TestGenesisBlock.Hash(), sends[1].Hash(), sends[2].Hash()} {
+		_, err := s.bs.GetBlock(h)
+		s.Nil(err)
+	}
+
+	confirmed, err := l.Confirmed(sends[0].Hash())
+	require.Nil(s.T(), err)
+	s.True(confirmed)
+
+	// A pruned send can still be received
+	open := &blocks.OpenBlock{
+		Source:         sends[0].Hash(),
+		Representative: dest,
+		Account:        dest,
+	}
+	open.Work = types.GenerateWorkForHash(open.GetRoot())
+	open.Signature = destKey.Sign(open.Hash().Slice())
+	require.Nil(s.T(), l.AddBlock(open))
+
+	acc, err := s.as.GetAccount(dest)
+	require.Nil(s.T(), err)
+	s.Equal(uint128.FromInts(0, 1000), acc.Balance)
+
+	// The history ends where the chain has been pruned
+	entries, err := l.AccountHistory(blocks.TestGenesisBlock.Account, types.BlockHash{}, 0, 0, nil)
+	require.Nil(s.T(), err)
+	require.Len(s.T(), entries, 1)
+	s.Equal(sends[2].Hash(), entries[0].Hash)
+
+	_, err = l.Rollback(open.Hash())
+	require.Nil(s.T(), err)
+
+	p, err := l.GetPending(dest, sends[0].Hash())
+	require.Nil(s.T(), err)
+	s.Equal(blocks.TestGenesisBlock.Account, p.Source)
+
+	s.Equal(ErrLedgerPruned, l.Export(new(bytes.Buffer)))
+}
+
+func (s *LedgerTestSuite) TestRollbackPruned() {
+	l := NewLedger(s.st)
+	err := l.Init()
+	require.Nil(s.T(), err)
+
+	genesis := blocks.TestGenesisBlock.Account
+	rep, _, err := types.GenerateKey(nil)
+	require.Nil(s.T(), err)
+
+	var sends []*blocks.SendBlock
+	previous := blocks.TestGenesisBlock.Hash()
+	for i := uint64(1); i <= 2; i++ {
+		send := &blocks.SendBlock{
+			Previous:    previous,
+			Destination: rep,
+			Balance:     blocks.GenesisAmount.Sub(uint128.FromInts(0, 1000*i)),
+		}
+		send.Work = types.GenerateWorkForHash(send.GetRoot())
+		send.Signature = s.key.Sign(send.Hash().Slice())
+		require.Nil(s.T(), l.AddBlock(send))
+
+		sends = append(sends, send)
+		previous = send.Hash()
+	}
+
+	require.Nil(s.T(), l.Confirm(sends[1].Hash()))
+	pruned, err := l.Prune()
+	require.Nil(s.T(), err)
+	s.Equal(1, pruned)
+
+	// Rolling back a change walks back to the pruned blocks
+	change := &blocks.ChangeBlock{
+		Previous:       sends[1].Hash(),
+		Representative: rep,
+	}
+	change.Work = types.GenerateWorkForHash(change.GetRoot())
+	change.Signature = s.key.Sign(change.Hash().Slice())
+	require.Nil(s.T(), l.AddBlock(change))
+
+	_, err = l.Rollback(change.Hash())
+	require.Nil(s.T(), err)
+
+	acc, err := s.as.GetAccount(genesis)
+	require.Nil(s.T(), err)
+	s.Equal(sends[1].Hash(), acc.Head)
+	s.True(acc.Rep.Equal(genesis))
+
+	w, err := l.Weight(genesis)
+	require.Nil(s.T(), err)
+	s.Equal(acc.Balance, w)
+}
+
+func (s *LedgerTestSuite) TestPrunedHistory() {
+	l := NewLedger(s.st)
+	err := l.Init()
+	require.Nil(s.T(), err)
+
+	dest, destKey, err := types.GenerateKey(nil)
+	require.Nil(s.T(), err)
+
+	var sends []*blocks.SendBlock
+	previous := blocks.TestGenesisBlock.Hash()
+	for i := uint64(1); i <= 3; i++ {
+		send := &blocks.SendBlock{
+			Previous:    previous,
+			Destination: dest,
+			Balance:     blocks.GenesisAmount.Sub(uint128.FromInts(0, 1000*i)),
+		}
+		send.Work = types.GenerateWorkForHash(send.GetRoot())
+		send.Signature = s.key.Sign(send.Hash().Slice())
+		require.Nil(s.T(), l.AddBlock(send))
+
+		sends = append(sends, send)
+		previous = send.Hash()
+	}
+
+	open := &blocks.OpenBlock{
+		Source:         sends[0].Hash(),
+		Representative: dest,
+		Account:        dest,
+	}
+	open.Work = types.GenerateWorkForHash(open.GetRoot())
+	open.Signature = destKey.Sign(open.Hash().Slice())
+	require.Nil(s.T(), l.AddBlock(open))
+
+	var receives []*blocks.ReceiveBlock
+	previous = open.Hash()
+	for _, send := range sends[1:] {
+		receive := &blocks.ReceiveBlock{
+			Previous: previous,
+			Source:   send.Hash(),
+		}
+		receive.Work = types.GenerateWorkForHash(receive.GetRoot())
+		receive.Signature = destKey.Sign(receive.Hash().Slice())
+		require.Nil(s.T(), l.AddBlock(receive))
+
+		receives = append(receives, receive)
+		previous = receive.Hash()
+	}
+
+	// The open block of dest is pruned
+	require.Nil(s.T(), l.Confirm(receives[0].Hash()))
+	_, err = l.Prune()
+	require.Nil(s.T(), err)
+	_, err = s.bs.GetBlock(open.Hash())
+	require.Equal(s.T(), blocks.ErrPruned, err)
+
+	for _, reverse := range []bool{false, true} {
+		entries, err := l.AccountHistory(dest, types.BlockHash{}, 0, 0, &HistoryFilter{Reverse: reverse})
+		require.Nil(s.T(), err)
+		require.Len(s.T(), entries, 1)
+		s.Equal(receives[1].Hash(), entries[0].Hash)
+	}
+
+	// The genesis chain has a pruned block right after the open
+	entries, err := l.AccountHistory(blocks.TestGenesisBlock.Account, types.BlockHash{}, 0, 0, &HistoryFilter{Reverse: true})
+	require.Nil(s.T(), err)
+	require.Len(s.T(), entries, 1)
+	s.Equal(sends[2].Hash(), entries[0].Hash)
+}
+
+func (s *LedgerTestSuite) TestVerify() {
+	l := NewLedger(s.st)
+	err := l.Init()
+	require.Nil(s.T(), err)
+
+	dest, destKey, err := types.GenerateKey(nil)
+	require.Nil(s.T(), err)
+
+	send := &blocks.SendBlock{
+		Previous:    blocks.TestGenesisBlock.Hash(),
+		Destination: dest,
+		Balance:     blocks.GenesisAmount.Sub(uint128.FromInts(0, 1000)),
+	}
+	send.Work = types.GenerateWorkForHash(send.GetRoot())
+	send.Signature = s.key.Sign(send.Hash().Slice())
+	require.Nil(s.T(), l.AddBlock(send))
+
+	open := &blocks.OpenBlock{
+		Source:         send.Hash(),
+		Representative: dest,
+		Account:        dest,
+	}
+	open.Work = types.GenerateWorkForHash(open.GetRoot())
+	open.Signature = destKey.Sign(open.Hash().Slice())
+	require.Nil(s.T(), l.AddBlock(open))
+
+	unreceived := &blocks.SendBlock{
+		Previous:    open.Hash(),
+		Destination: blocks.TestGenesisBlock.Account,
+		Balance:     uint128.FromInts(0, 400),
+	}
+	unreceived.Work = types.GenerateWorkForHash(unreceived.GetRoot())
+	unreceived.Signature = destKey.Sign(unreceived.Hash().Slice())
+	require.Nil(s.T(), l.AddBlock(unreceived))
+
+	found, err := l.Verify(false)
+	require.Nil(s.T(), err)
+	s.Len(found, 0)
+
+	// Corrupt the data derived from the blocks
+	acc, err := s.as.GetAccount(dest)
+	require.Nil(s.T(), err)
+	acc.Balance = uint128.FromInts(0, 1)
+	require.Nil(s.T(), s.as.SetAccount(acc))
+
+	ps := NewPendingStore(s.st)
+	require.Nil(s.T(), ps.DeletePending(blocks.TestGenesisBlock.Account, unreceived.Hash()))
+
+	ws := NewWeightStore(s.st)
+	require.Nil(s.T(), ws.AddWeight(dest, uint128.FromInts(0, 5)))
+
+	found, err = l.Verify(false)
+	require.Nil(s.T(), err)
+	s.Len(found, 3)
+	for _, d := range found {
+		s.False(d.Repaired)
+	}
+
+	found, err = l.Verify(true)
+	require.Nil(s.T(), err)
+	s.Len(found, 3)
+	for _, d := range found {
+		s.True(d.Repaired)
+	}
+
+	found, err = l.Verify(false)
+	require.Nil(s.T(), err)
+	s.Len(found, 0)
+
+	acc, err = s.as.GetAccount(dest)
+	require.Nil(s.T(), err)
+	s.Equal(uint128.FromInts(0, 400), acc.Balance)
+
+	p, err := l.GetPending(blocks.TestGenesisBlock.Account, unreceived.Hash())
+	require.Nil(s.T(), err)
+	s.Equal(uint128.FromInts(0, 600), p.Amount)
+
+	w, err := l.Weight(dest)
+	require.Nil(s.T(), err)
+	s.Equal(uint128.FromInts(0, 400), w)
+}
+
+func (s *LedgerTestSuite) TestVerifyPruned() {
+	l := NewLedger(s.st)
+	err := l.Init()
+	require.Nil(s.T(), err)
+
+	a, aKey, err := types.GenerateKey(nil)
+	require.Nil(s.T(), err)
+	b, bKey, err := types.GenerateKey(nil)
+	require.Nil(s.T(), err)
+
+	var sends []*blocks.SendBlock
+	previous := blocks.TestGenesisBlock.Hash()
+	for i, dest := range []types.PubKey{a, b, b} {
+		send := &blocks.SendBlock{
+			Previous:    previous,
+			Destination: dest,
+			Balance:     blocks.GenesisAmount.Sub(uint128.FromInts(0, 1000*uint64(i+1))),
+		}
+		send.Work = types.GenerateWorkForHash(send.GetRoot())
+		send.Signature = s.key.Sign(send.Hash().Slice())
+		require.Nil(s.T(), l.AddBlock(send))
+
+		sends = append(sends, send)
+		previous = send.Hash()
+	}
+
+	openA := &blocks.OpenBlock{Source: sends[0].Hash(), Representative: a, Account: a}
+	openA.Work = types.GenerateWorkForHash(openA.GetRoot())
+	openA.Signature = aKey.Sign(openA.Hash().Slice())
+	require.Nil(s.T(), l.AddBlock(openA))
+
+	sendA := &blocks.SendBlock{
+		Previous:    openA.Hash(),
+		Destination: blocks.TestGenesisBlock.Account,
+		Balance:     uint128.FromInts(0, 400),
+	}
+	sendA.Work = types.GenerateWorkForHash(sendA.GetRoot())
+	sendA.Signature = aKey.Sign(sendA.Hash().Slice())
+	require.Nil(s.T(), l.AddBlock(sendA))
+
+	openB := &blocks.OpenBlock{Source: sends[1].Hash(), Representative: b, Account: b}
+	openB.Work = types.GenerateWorkForHash(openB.GetRoot())
+	openB.Signature = bKey.Sign(openB.Hash().Slice())
+	require.Nil(s.T(), l.AddBlock(openB))
+
+	receiveB := &blocks.ReceiveBlock{Previous: openB.Hash(), Source: sends[2].Hash()}
+	receiveB.Work = types.GenerateWorkForHash(receiveB.GetRoot())
+	receiveB.Signature = bKey.Sign(receiveB.Hash().Slice())
+	require.Nil(s.T(), l.AddBlock(receiveB))
+
+	// The kept base blocks are a send which hasn't been received,
+	// and a receive of the base send of the genesis chain
+	require.Nil(s.T(), l.Confirm(sendA.Hash()))
+	require.Nil(s.T(), l.Confirm(receiveB.Hash()))
+	_, err = l.Prune()
+	require.Nil(s.T(), err)
+	for _, h := range []types.BlockHash{openA.Hash(), openB.Hash(), sends[1].Hash()} {
+		_, err = s.bs.GetBlock(h)
+		require.Equal(s.T(), blocks.ErrPruned, err)
+	}
+
+	found, err := l.Verify(true)
+	require.Nil(s.T(), err)
+	s.Len(found, 0)
+
+	p, err := l.GetPending(blocks.TestGenesisBlock.Account, sendA.Hash())
+	require.Nil(s.T(), err)
+	s.Equal(uint128.FromInts(0, 600), p.Amount)
+
+	_, err = l.GetPending(b, sends[2].Hash())
+	s.Equal(store.ErrKeyNotFound, err)
+
+	// Pending entries of sends with an unknown amount aren't recreated
+	ps := NewPendingStore(s.st)
+	require.Nil(s.T(), ps.DeletePending(blocks.TestGenesisBlock.Account, sendA.Hash()))
+	found, err = l.Verify(true)
+	require.Nil(s.T(), err)
+	require.Len(s.T(), found, 1)
+	s.False(found[0].Repaired)
+}
+
+func (s *LedgerTestSuite) TestEpoch() {
+	l := NewLedger(s.st)
+	err := l.Init()
+	require.Nil(s.T(), err)
+
+	dest, destKey, err := types.GenerateKey(nil)
+	require.Nil(s.T(), err)
+
+	amount := uint128.FromInts(0, 1000)
+	send := &blocks.SendBlock{
+		Previous:    blocks.TestGenesisBlock.Hash(),
+		Destination: dest,
+		Balance:     blocks.GenesisAmount.Sub(amount),
+	}
+	send.Work = types.GenerateWorkForHash(send.GetRoot())
+	send.Signature = s.key.Sign(send.Hash().Slice())
+	require.Nil(s.T(), l.AddBlock(send))
+
+	open := &blocks.OpenBlock{
+		Source:         send.Hash(),
+		Representative: dest,
+		Account:        dest,
+	}
+	open.Work = types.GenerateWorkForHash(open.GetRoot())
+	open.Signature = destKey.Sign(open.Hash().Slice())
+	require.Nil(s.T(), l.AddBlock(open))
+
+	epoch := &blocks.UtxBlock{
+		Account:        dest,
+		Previous:       open.Hash(),
+		Representative: dest,
+		Balance:        amount,
+		Link:           blocks.EpochLink,
+	}
+	epoch.Work = types.GenerateWorkForHash(epoch.GetRoot())
+
+	// Only the epoch signer can upgrade accounts
+	epoch.Signature = destKey.Sign(epoch.Hash().Slice())
+	s.IsType(&blocks.SignatureError{}, l.AddBlock(epoch))
+
+	epoch.Balance = amount.Sub(uint128.FromInts(0, 1))
+	epoch.Signature = s.key.Sign(epoch.Hash().Slice())
+	s.Equal(ErrBadEpoch, l.AddBlock(epoch))
+
+	epoch.Balance = amount
+	epoch.Signature = s.key.Sign(epoch.Hash().Slice())
+	require.Nil(s.T(), l.AddBlock(epoch))
+
+	acc, err := s.as.GetAccount(dest)
+	require.Nil(s.T(), err)
+	s.Equal(blocks.Epoch1, acc.Epoch)
+	s.Equal(epoch.Hash(), acc.Head)
+
+	// Upgraded chains only take universal blocks
+	legacy := &blocks.SendBlock{
+		Previous:    epoch.Hash(),
+		Destination: blocks.TestGenesisBlock.Account,
+		Balance:     uint128.FromInts(0, 400),
+	}
+	legacy.Work = types.GenerateWorkForHash(legacy.GetRoot())
+	legacy.Signature = destKey.Sign(legacy.Hash().Slice())
+	s.Equal(ErrLegacyBlock, l.AddBlock(legacy))
+
+	again := &blocks.UtxBlock{
+		Account:        dest,
+		Previous:       epoch.Hash(),
+		Representative: dest,
+		Balance:        amount,
+		Link:           blocks.EpochLink,
+	}
+	again.Work = types.GenerateWorkForHash(again.GetRoot())
+	again.Signature = s.key.Sign(again.Hash().Slice())
+	s.Equal(ErrEpochUpgraded, l.AddBlock(again))
+
+	found, err := l.Verify(false)
+	require.Nil(s.T(), err)
+	s.Len(found, 0)
+
+	_, err = l.Rollback(epoch.Hash())
+	require.Nil(s.T(), err)
+
+	acc, err = s.as.GetAccount(dest)
+	require.Nil(s.T(), err)
+	s.Equal(uint8(0), acc.Epoch)
+	s.Equal(open.Hash(), acc.Head)
+}
+
+func (s *LedgerTestSuite) TestAddBlocks() {
+	l := NewLedger(s.st)
+	err := l.Init()
+	require.Nil(s.T(), err)
+
+	dest, destKey, err := types.GenerateKey(nil)
+	require.Nil(s.T(), err)
+
+	send := &blocks.SendBlock{
+		Previous:    blocks.TestGenesisBlock.Hash(),
+		Destination: dest,
+		Balance:     blocks.GenesisAmount.Sub(uint128.FromInts(0, 1000)),
+	}
+	send.Work = types.GenerateWorkForHash(send.GetRoot())
+	send.Signature = s.key.Sign(send.Hash().Slice())
+
+	// Receives the send applied earlier in the same batch
+	open := &blocks.OpenBlock{
+		Source:         send.Hash(),
+		Representative: dest,
+		Account:        dest,
+	}
+	open.Work = types.GenerateWorkForHash(open.GetRoot())
+	open.Signature = destKey.Sign(open.Hash().Slice())
+
+	forged := &blocks.ChangeBlock{
+		Previous:       send.Hash(),
+		Representative: dest,
+	}
+	forged.Work = types.GenerateWorkForHash(forged.GetRoot())
+	forged.Signature = destKey.Sign(forged.Hash().Slice())
+
+	change := &blocks.ChangeBlock{
+		Previous:       send.Hash(),
+		Representative: dest,
+	}
+	change.Work = types.GenerateWorkForHash(change.GetRoot())
+	change.Signature = s.key.Sign(change.Hash().Slice())
+
+	// Its previous block isn't part of the batch
+	gap := &blocks.ChangeBlock{
+		Previous:       types.BlockHash{1},
+		Representative: dest,
+	}
+	gap.Work = types.GenerateWorkForHash(gap.GetRoot())
+	gap.Signature = s.key.Sign(gap.Hash().Slice())
+
+	results, err := l.AddBlocks([]blocks.Block{send, open, forged, change, gap})
+	require.Nil(s.T(), err)
+	require.Len(s.T(), results, 5)
+	s.Nil(results[0])
+	s.Nil(results[1])
+	s.IsType(&blocks.SignatureError{}, results[2])
+	s.Nil(results[3])
+	s.Equal(ErrGapPrevious, results[4])
+
+	acc, err := s.as.GetAccount(blocks.TestGenesisBlock.Account)
+	require.Nil(s.T(), err)
+	s.Equal(change.Hash(), acc.Head)
+
+	acc, err = s.as.GetAccount(dest)
+	require.Nil(s.T(), err)
+	s.Equal(open.Hash(), acc.Head)
+
+	count, err := s.bs.CountUnchecked()
+	require.Nil(s.T(), err)
+	s.EqualValues(1, count)
+}
+
+func (s *LedgerTestSuite) TestChain() {
+	l := NewLedger(s.st)
+	err := l.Init()
+	require.Nil(s.T(), err)
+
+	hashes := []types.BlockHash{blocks.TestGenesisBlock.Hash()}
+	for i := 0; i < 3; i++ {
+		dest, _, err := types.GenerateKey(nil)
+		require.Nil(s.T(), err)
+
+		b := &blocks.SendBlock{
+			Previous:    hashes[len(hashes)-1],
+			Destination: dest,
+			Balance:     blocks.GenesisAmount.Sub(uint128.FromInts(0, uint64(i+1)*1000)),
+		}
+		b.Work = types.GenerateWorkForHash(b.GetRoot())
+		b.Signature = s.key.Sign(b.Hash().Slice())
+		require.Nil(s.T(), l.AddBlock(b))
+		hashes = append(hashes, b.Hash())
+	}
+
+	var heads []types.BlockHash
+	err = l.Frontiers(nil, func(pub types.PubKey, head types.BlockHash) error {
+		heads = append(heads, head)
+		return nil
+	})
+	require.Nil(s.T(), err)
+	s.Equal([]types.BlockHash{hashes[3]}, heads)
+
+	// Accounts are walked from the start account on
+	genesis := blocks.TestGenesisBlock.Account
+	for start, expected := range map[string]int{string(genesis): 1, string(genesis) + "\x00": 0} {
+		heads = nil
+		err = l.Frontiers(types.PubKey(start), func(pub types.PubKey, head types.BlockHash) error {
+			heads = append(heads, head)
+			return nil
+		})
+		require.Nil(s.T(), err)
+		s.Len(heads, expected)
+	}
+
+	var chain []types.BlockHash
+	collect := func(b blocks.Block) error {
+		chain = append(chain, b.Hash())
+		return nil
+	}
+
+	err = l.Chain(blocks.TestGenesisBlock.Account, hashes[1], collect)
+	require.Nil(s.T(), err)
+	s.Equal([]types.BlockHash{hashes[3], hashes[2]}, chain)
+
+	chain = nil
+	err = l.Chain(blocks.TestGenesisBlock.Account, types.BlockHash{}, collect)
+	require.Nil(s.T(), err)
+	s.Equal([]types.BlockHash{hashes[3], hashes[2], hashes[1], hashes[0]}, chain)
+
+	dest, _, err := types.GenerateKey(nil)
+	require.Nil(s.T(), err)
+	s.Equal(ErrAccountNotFound, l.Chain(dest, types.BlockHash{}, collect))
+}
+
+func (s *LedgerTestSuite) TestFrontierPages() {
+	l := NewLedger(s.st)
+	err := l.Init()
+	require.Nil(s.T(), err)
+
+	count := 2*frontierPageSize + 1
+	for i := 0; i < count; i++ {
+		acc := account.NewAccount()
+		acc.PublicKey, _, err = types.GenerateKey(nil)
+		require.Nil(s.T(), err)
+		acc.Head = types.BlockHash{byte(i)}
+		require.Nil(s.T(), s.as.SetAccount(acc))
+	}
+
+	// The store isn't held while fn is called, so it may write to it
+	var pubs []types.PubKey
+	err = l.Frontiers(nil, func(pub types.PubKey, head types.BlockHash) error {
+		pubs = append(pubs, pub)
+		return s.st.Set([]byte("frontier"), pub)
+	})
+	require.Nil(s.T(), err)
+	require.Len(s.T(), pubs, count+1)
+	for i := 1; i < len(pubs); i++ {
+		s.True(bytes.Compare(pubs[i-1], pubs[i]) < 0)
+	}
+}
+
+func (s *LedgerTestSuite) TestLowWeightQuorum() {
+	l := NewLedger(s.st)
+	err := l.Init()
+	require.Nil(s.T(), err)
+
+	dest, destKey, err := types.GenerateKey(nil)
+	require.Nil(s.T(), err)
+
+	send := &blocks.SendBlock{
+		Previous:    blocks.TestGenesisBlock.Hash(),
+		Destination: dest,
+		Balance:     blocks.GenesisAmount.Sub(uint128.FromInts(0, 1000)),
+	}
+	send.Work = types.GenerateWorkForHash(send.GetRoot())
+	send.Signature = s.key.Sign(send.Hash().Slice())
+	require.Nil(s.T(), l.AddBlock(send))
+
+	open := &blocks.OpenBlock{
+		Source:         send.Hash(),
+		Representative: dest,
+		Account:        dest,
+	}
+	open.Work = types.GenerateWorkForHash(open.GetRoot())
+	open.Signature = destKey.Sign(open.Hash().Slice())
+	require.Nil(s.T(), l.AddBlock(open))
+
+	// The only rep seen online falls short of the minimum
+	confirmed, err := l.ProcessVote(dest, 1, open)
+	require.Nil(s.T(), err)
+	s.False(confirmed)
+
+	ok, err := l.Confirmed(open.Hash())
+	require.Nil(s.T(), err)
+	s.False(ok)
+}
+
 func TestLedgerTestSuite(t *testing.T) {
 	suite.Run(t, new(LedgerTestSuite))
 }
@@ -0,0 +1,239 @@
+package ledger
+
+import (
+	"bytes"
+	"encoding/gob"
+
+	"github.com/s1na/nano/account"
+	"github.com/s1na/nano/blocks"
+	"github.com/s1na/nano/store"
+	"github.com/s1na/nano/types"
+	"github.com/s1na/nano/types/uint128"
+
+	"github.com/pkg/errors"
+)
+
+func init() {
+	store.RegisterMigration(store.Migration{
+		Version: 1,
+		Name:    "binary pending encoding",
+		Prefix:  []byte("pending:"),
+		Migrate: migrateGobPending,
+	})
+	store.RegisterMigration(store.Migration{
+		Version: 2,
+		Name:    "representative weights",
+		Prefix:  []byte("account:"),
+		Migrate: migrateWeight,
+	})
+	store.RegisterMigration(store.Migration{
+		Version: 3,
+		Name:    "block sideband",
+		Prefix:  []byte("account:"),
+		Migrate: migrateSidebands,
+	})
+}
+
+// migrateGobPending re-encodes a pending entry,
+// as they were gob encoded before version 1.
+func migrateGobPending(txn store.Txn, k, v []byte) error {
+	var p *Pending
+	if err := gob.NewDecoder(bytes.NewBuffer(v)).Decode(&p); err != nil {
+		return err
+	}
+
+	return txn.Set(k, encodePending(p))
+}
+
+// migrateWeight adds the balance of an account to
+// the weight of the representative it delegates to.
+func migrateWeight(txn store.Txn, k, v []byte) error {
+	a, err := account.NewAccountStore(txn).GetAccount(k[len("account:"):])
+	if err != nil {
+		return err
+	}
+
+	return NewWeightStore(txn).AddWeight(a.Rep, a.Balance)
+}
+
+// migrateSidebands derives the sideband of the blocks in the chain
+// of an account, which are walked back from the account head, and
+// records each of them as the successor of its root. The balance is
+// carried forward from the open block. The time the blocks arrived
+// at is unknown, and left zero.
+func migrateSidebands(txn store.Txn, k, v []byte) error {
+	l := new(Ledger)
+	l.setStores(txn)
+
+	a, err := l.as.GetAccount(k[len("account:"):])
+	if err != nil {
+		return err
+	}
+
+	var chain []blocks.Block
+	for hash := a.Head; ; {
+		b, err := l.bs.GetBlock(hash)
+		if err != nil {
+			return errors.Wrapf(err, "failed to fetch block %s", hash)
+		}
+
+		chain = append(chain, b)
+		if ub, ok := b.(*blocks.UtxBlock); b.Type() == blocks.Open || ok && ub.IsOpen() {
+			break
+		}
+
+		hash = b.GetPrevious()
+	}
+
+	// Derive the balances from the open block onwards
+	var balance uint128.Uint128
+	for i := len(chain) - 1; i >= 0; i-- {
+		b := chain[i]
+
+		var err error
+		switch b := b.(type) {
+		case *blocks.SendBlock:
+			balance = b.Balance
+		case *blocks.UtxBlock:
+			balance = b.Balance
+		case *blocks.ReceiveBlock:
+			var amount uint128.Uint128
+			amount, err = l.migratedAmount(b.Source)
+			balance = balance.Add(amount)
+		case *blocks.OpenBlock:
+			if b.Hash() == blocks.GenesisBlock.Hash() {
+				balance = blocks.GenesisAmount
+			} else {
+				balance, err = l.migratedAmount(b.Source)
+			}
+		}
+		if err != nil {
+			return err
+		}
+
+		var successor types.BlockHash
+		if i > 0 {
+			successor = chain[i-1].Hash()
+		}
+
+		sb := &blocks.Sideband{
+			Account:   a.PublicKey,
+			Successor: successor,
+			Height:    uint64(len(chain) - i),
+			Balance:   balance,
+		}
+		if err := l.bs.SetSideband(b.Hash(), sb); err != nil {
+			return err
+		}
+
+		if err := l.bs.SetRoot(b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// migratedAmount returns the amount sent by the send with the given
+// hash. The balance before the send is taken from the sideband of its
+// previous block once that's been migrated, and derived from the
+// blocks otherwise.
+func (l *Ledger) migratedAmount(hash types.BlockHash) (uint128.Uint128, error) {
+	b, err := l.bs.GetBlock(hash)
+	if err != nil {
+		return uint128.Uint128{}, errors.Wrapf(err, "failed to fetch block %s", hash)
+	}
+
+	var balance uint128.Uint128
+	switch b := b.(type) {
+	case *blocks.SendBlock:
+		balance = b.Balance
+	case *blocks.UtxBlock:
+		balance = b.Balance
+	default:
+		return uint128.Uint128{}, ErrBadSource
+	}
+
+	sb, err := l.bs.GetSideband(b.GetPrevious())
+	if err != nil {
+		return uint128.Uint128{}, errors.Wrapf(err, "failed to fetch block %s", b.GetPrevious())
+	}
+
+	// Sidebands stored before they were derived have no height
+	previous := sb.Balance
+	if sb.Height == 0 {
+		if previous, err = l.chainBalance(b.GetPrevious()); err != nil {
+			return uint128.Uint128{}, err
+		}
+	}
+
+	return previous.Sub(balance), nil
+}
+
+// chainBalance returns the balance of the account after the block with
+// the given hash, computed from the blocks alone, as only send and
+// universal blocks state it.
+func (l *Ledger) chainBalance(hash types.BlockHash) (uint128.Uint128, error) {
+	var received uint128.Uint128
+	for {
+		b, err := l.bs.GetBlock(hash)
+		if err != nil {
+			return uint128.Uint128{}, errors.Wrapf(err, "failed to fetch block %s", hash)
+		}
+
+		switch b := b.(type) {
+		case *blocks.SendBlock:
+			return received.Add(b.Balance), nil
+		case *blocks.UtxBlock:
+			return received.Add(b.Balance), nil
+		case *blocks.ChangeBlock:
+			hash = b.Previous
+		case *blocks.ReceiveBlock:
+			amount, err := l.sentAmount(b.Source)
+			if err != nil {
+				return uint128.Uint128{}, err
+			}
+
+			received = received.Add(amount)
+			hash = b.Previous
+		case *blocks.OpenBlock:
+			if b.Hash() == blocks.GenesisBlock.Hash() {
+				return received.Add(blocks.GenesisAmount), nil
+			}
+
+			amount, err := l.sentAmount(b.Source)
+			if err != nil {
+				return uint128.Uint128{}, err
+			}
+
+			return received.Add(amount), nil
+		default:
+			return uint128.Uint128{}, errors.New("unsupported block type")
+		}
+	}
+}
+
+// sentAmount returns the amount sent by the send with the given hash.
+func (l *Ledger) sentAmount(hash types.BlockHash) (uint128.Uint128, error) {
+	b, err := l.bs.GetBlock(hash)
+	if err != nil {
+		return uint128.Uint128{}, errors.Wrapf(err, "failed to fetch block %s", hash)
+	}
+
+	var balance uint128.Uint128
+	switch b := b.(type) {
+	case *blocks.SendBlock:
+		balance = b.Balance
+	case *blocks.UtxBlock:
+		balance = b.Balance
+	default:
+		return uint128.Uint128{}, ErrBadSource
+	}
+
+	previous, err := l.chainBalance(b.GetPrevious())
+	if err != nil {
+		return uint128.Uint128{}, err
+	}
+
+	return previous.Sub(balance), nil
+}
@@ -0,0 +1,80 @@
+package ledger
+
+import (
+	"sync"
+	"time"
+
+	"github.com/s1na/nano/types"
+	"github.com/s1na/nano/types/uint128"
+)
+
+// OnlineWindow is how long a representative counts
+// as online after it was last seen voting.
+var OnlineWindow = 5 * time.Minute
+
+// onlineReps tracks when representatives were last seen
+// voting, which is only kept in memory.
+type onlineReps struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+	reps map[string]types.PubKey
+}
+
+func newOnlineReps() *onlineReps {
+	o := new(onlineReps)
+
+	o.seen = make(map[string]time.Time)
+	o.reps = make(map[string]types.PubKey)
+
+	return o
+}
+
+func (o *onlineReps) observe(rep types.PubKey) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	addr := rep.Address()
+	o.seen[addr] = time.Now()
+	o.reps[addr] = rep
+}
+
+// online returns the representatives seen within OnlineWindow,
+// and forgets about the ones which haven't.
+func (o *onlineReps) online() []types.PubKey {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	reps := make([]types.PubKey, 0, len(o.seen))
+	for addr, t := range o.seen {
+		if time.Since(t) > OnlineWindow {
+			delete(o.seen, addr)
+			delete(o.reps, addr)
+			continue
+		}
+
+		reps = append(reps, o.reps[addr])
+	}
+
+	return reps
+}
+
+// ObserveRep records that rep was seen voting.
+func (l *Ledger) ObserveRep(rep types.PubKey) {
+	l.online.observe(rep)
+}
+
+// OnlineWeight estimates the voting weight currently online, as
+// the weight of the representatives seen voting within OnlineWindow.
+func (l *Ledger) OnlineWeight() (uint128.Uint128, error) {
+	var total uint128.Uint128
+	for _, rep := range l.online.online() {
+		w, err := l.Weight(rep)
+		if err != nil {
+			return uint128.Uint128{}, err
+		}
+
+		total = total.Add(w)
+	}
+
+	return total, nil
+}
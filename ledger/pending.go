@@ -0,0 +1,113 @@
+package ledger
+
+import (
+	"github.com/s1na/nano/store"
+	"github.com/s1na/nano/types"
+	"github.com/s1na/nano/types/uint128"
+)
+
+// Pending is a send which hasn't been received
+// by its destination account yet.
+type Pending struct {
+	Source types.PubKey
+	Amount uint128.Uint128
+}
+
+type PendingStore struct {
+	s store.ReadWriter
+}
+
+func NewPendingStore(store store.ReadWriter) *PendingStore {
+	s := new(PendingStore)
+
+	s.s = store
+
+	return s
+}
+
+func (s *PendingStore) SetPending(dest types.PubKey, hash types.BlockHash, p *Pending) error {
+	return s.s.Set(pendingKey(dest, hash), encodePending(p))
+}
+
+func (s *PendingStore) GetPending(dest types.PubKey, hash types.BlockHash) (*Pending, error) {
+	v, err := s.s.Get(pendingKey(dest, hash))
+	if err != nil {
+		return nil, err
+	}
+
+	return decodePending(v)
+}
+
+func (s *PendingStore) DeletePending(dest types.PubKey, hash types.BlockHash) error {
+	return s.s.Delete(pendingKey(dest, hash))
+}
+
+// GetPendings returns all sends waiting to be
+// received by dest, keyed by send block hash.
+func (s *PendingStore) GetPendings(dest types.PubKey) (map[types.BlockHash]*Pending, error) {
+	prefix := pendingKey(dest, types.BlockHash{})
+	prefix = prefix[:len(prefix)-len(types.BlockHash{})]
+
+	res, err := s.s.GetPrefixValues(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	pendings := make(map[types.BlockHash]*Pending)
+	for k, v := range res {
+		p, err := decodePending(v)
+		if err != nil {
+			return nil, err
+		}
+
+		pendings[types.BlockHashFromSlice([]byte(k)[len(prefix):])] = p
+	}
+
+	return pendings, nil
+}
+
+// Iterate calls fn for all pending entries, ordered by
+// destination and send block hash.
+func (s *PendingStore) Iterate(fn func(dest types.PubKey, hash types.BlockHash, p *Pending) error) error {
+	prefix := []byte("pending:")
+	return s.s.Iterate(prefix, func(k, v []byte) error {
+		p, err := decodePending(v)
+		if err != nil {
+			return err
+		}
+
+		k = k[len(prefix):]
+		if len(k) != 64 {
+			return store.ErrCorrupt
+		}
+
+		return fn(types.PubKeyFromSlice(k[:32]), types.BlockHashFromSlice(k[32:]), p)
+	})
+}
+
+func pendingKey(dest types.PubKey, hash types.BlockHash) []byte {
+	key := append([]byte("pending:"), dest...)
+	return append(key, hash.Slice()...)
+}
+
+// A stored pending entry is its source
+// account followed by the amount.
+func encodePending(p *Pending) []byte {
+	v := make([]byte, 32, 48)
+	copy(v, p.Source)
+
+	return append(v, p.Amount.GetBytes()...)
+}
+
+func decodePending(v []byte) (*Pending, error) {
+	if len(v) != 48 {
+		return nil, store.ErrCorrupt
+	}
+
+	p := &Pending{
+		Source: types.PubKeyFromSlice(v[:32]),
+		Amount: uint128.FromBytes(v[32:]),
+	}
+
+	return p, nil
+}
@@ -0,0 +1,108 @@
+package ledger
+
+import (
+	"github.com/s1na/nano/blocks"
+	"github.com/s1na/nano/types"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// Prune discards the blocks of each account chain below its
+// confirmation height. The last confirmed block and the ones
+// after it are kept to validate new blocks, as are the accounts
+// and pending entries. Returns the number of pruned blocks.
+func (l *Ledger) Prune() (int, error) {
+	accounts, err := l.as.GetAccounts()
+	if err != nil {
+		return 0, err
+	}
+
+	total := 0
+	for _, acc := range accounts {
+		if acc.ConfirmationHeight < 2 {
+			continue
+		}
+
+		var n int
+		err := l.update(func(l *Ledger) error {
+			var err error
+			n, err = l.pruneAccount(acc.PublicKey)
+			return err
+		})
+		if err != nil {
+			return total, err
+		}
+
+		total += n
+	}
+
+	return total, nil
+}
+
+func (l *Ledger) pruneAccount(pub types.PubKey) (int, error) {
+	acc, err := l.as.GetAccount(pub)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to fetch account %s", pub.Address())
+	}
+
+	// Walk back from the head to the last confirmed block
+	hash := acc.Head
+	var b blocks.Block
+	var height uint64
+	for {
+		if b, err = l.bs.GetBlock(hash); err != nil {
+			return 0, errors.Wrapf(err, "failed to fetch block %s", hash)
+		}
+
+		sb, err := l.bs.GetSideband(hash)
+		if err != nil {
+			return 0, err
+		}
+
+		height = sb.Height
+		if height <= acc.ConfirmationHeight {
+			break
+		}
+
+		hash = b.GetPrevious()
+	}
+
+	n := 0
+	for ; height > 1; height-- {
+		hash = b.GetPrevious()
+		if hash == blocks.GenesisBlock.Hash() {
+			break
+		}
+
+		if b, err = l.bs.GetBlock(hash); err != nil {
+			// The rest of the chain has been pruned before
+			if err == blocks.ErrPruned {
+				break
+			}
+
+			return n, errors.Wrapf(err, "failed to fetch block %s", hash)
+		}
+
+		// The newest pruned block keeps the representative,
+		// which rolling back the blocks after it needs
+		var rep types.PubKey
+		if n == 0 {
+			if rep, err = l.representative(hash); err != nil {
+				return n, err
+			}
+		}
+
+		if err := l.bs.PruneBlock(hash, rep); err != nil {
+			return n, err
+		}
+
+		n++
+	}
+
+	if n > 0 {
+		log.WithFields(log.Fields{"account": acc.Address(), "count": n}).Debug("Pruned blocks")
+	}
+
+	return n, nil
+}
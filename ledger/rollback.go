@@ -0,0 +1,273 @@
+package ledger
+
+import (
+	"github.com/s1na/nano/account"
+	"github.com/s1na/nano/blocks"
+	"github.com/s1na/nano/types"
+	"github.com/s1na/nano/types/uint128"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	ErrRollbackGenesis = errors.New("cannot roll back the genesis block")
+	ErrReceiveNotFound = errors.New("cannot find the block receiving a send")
+)
+
+// Rollback undoes the block with the given hash, along with the blocks
+// succeeding it in its chain, and the blocks on other chains receiving
+// any of the sends among them. Balances, heads, representatives, weights
+// and pending entries are restored within one transaction, and the
+// removed blocks are returned, most recent first. The conflicts on
+// the roots of the removed blocks are dropped.
+func (l *Ledger) Rollback(hash types.BlockHash) ([]blocks.Block, error) {
+	var removed []blocks.Block
+	err := l.update(func(l *Ledger) error {
+		pub, err := l.chainAccount(hash)
+		if err != nil {
+			return err
+		}
+
+		removed, err = l.rollback(pub, hash)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return removed, nil
+}
+
+// rollback undoes the head of the chain of pub, until
+// the block with the given hash has been undone.
+func (l *Ledger) rollback(pub types.PubKey, hash types.BlockHash) ([]blocks.Block, error) {
+	var removed []blocks.Block
+	for {
+		acc, err := l.as.GetAccount(pub)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to fetch account %s", pub.Address())
+		}
+
+		head := acc.Head
+		rs, err := l.rollbackHead(acc)
+		if err != nil {
+			return nil, err
+		}
+
+		removed = append(removed, rs...)
+		if head == hash {
+			return removed, nil
+		}
+	}
+}
+
+// rollbackHead undoes the head block of acc, and first the blocks
+// depending on it when it's a send which has been received.
+func (l *Ledger) rollbackHead(acc *account.Account) ([]blocks.Block, error) {
+	b, err := l.bs.GetBlock(acc.Head)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to fetch block %s", acc.Head)
+	}
+
+	if b.Hash() == blocks.GenesisBlock.Hash() {
+		return nil, ErrRollbackGenesis
+	}
+
+	sb, err := l.bs.GetSideband(b.Hash())
+	if err != nil {
+		return nil, err
+	}
+
+	if sb.Height <= acc.ConfirmationHeight {
+		return nil, ErrRollbackConfirmed
+	}
+
+	open := false
+	var previous types.BlockHash
+	switch b := b.(type) {
+	case *blocks.OpenBlock:
+		open = true
+	case *blocks.UtxBlock:
+		open = b.IsOpen()
+		previous = b.Previous
+	default:
+		previous = b.GetPrevious()
+	}
+
+	// The state of the account before the block
+	var balance uint128.Uint128
+	var rep types.PubKey
+	if !open {
+		if balance, err = l.balance(previous); err != nil {
+			return nil, err
+		}
+
+		// Only the blocks naming a representative change it
+		rep = acc.Rep
+		if b.Type() == blocks.Change || b.Type() == blocks.Utx {
+			if rep, err = l.representative(previous); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	var removed []blocks.Block
+	var dest types.PubKey
+	var source types.BlockHash
+	switch b := b.(type) {
+	case *blocks.SendBlock:
+		dest = b.Destination
+	case *blocks.ReceiveBlock:
+		source = b.Source
+	case *blocks.OpenBlock:
+		source = b.Source
+	case *blocks.UtxBlock:
+		if b.IsSend(balance) {
+			dest = b.Link
+		} else if b.IsReceive(balance) {
+			source = b.LinkHash()
+		}
+	}
+
+	if len(dest) != 0 {
+		if _, err := l.pending(dest, b.Hash()); err == ErrNotPending {
+			if removed, err = l.rollbackReceive(dest, b.Hash()); err != nil {
+				return nil, err
+			}
+		} else if err != nil {
+			return nil, err
+		}
+
+		if err := l.ps.DeletePending(dest, b.Hash()); err != nil {
+			return nil, err
+		}
+	}
+
+	if !source.IsZero() {
+		sender, err := l.chainAccount(source)
+		if err != nil {
+			return nil, err
+		}
+
+		p := &Pending{
+			Source: sender,
+			Amount: acc.Balance.Sub(balance),
+		}
+		if err := l.ps.SetPending(acc.PublicKey, source, p); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := l.moveWeight(acc.Rep, acc.Balance, rep, balance); err != nil {
+		return nil, err
+	}
+
+	if err := l.bs.DeleteBlock(b.Hash()); err != nil {
+		return nil, err
+	}
+
+	// Rolling back a competitor resolves the fork on its root
+	if err := l.bs.DeleteConflict(b.GetRoot()); err != nil {
+		return nil, err
+	}
+
+	if open {
+		err = l.as.DeleteAccount(acc.PublicKey)
+	} else {
+		acc.Head = previous
+		acc.Balance = balance
+		acc.Rep = rep
+		if ub, ok := b.(*blocks.UtxBlock); ok && ub.IsEpoch() {
+			acc.Epoch = 0
+		}
+		err = l.as.SetAccount(acc)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	log.WithFields(log.Fields{"block": b.Hash(), "account": acc.Address()}).Info("Rolled back block")
+
+	return append(removed, b), nil
+}
+
+// rollbackReceive undoes the block of the chain of pub which
+// received the given send, along with its successors.
+func (l *Ledger) rollbackReceive(pub types.PubKey, send types.BlockHash) ([]blocks.Block, error) {
+	acc, err := l.as.GetAccount(pub)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to fetch account %s", pub.Address())
+	}
+
+	hash := acc.Head
+	for !hash.IsZero() {
+		b, err := l.bs.GetBlock(hash)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to fetch block %s", hash)
+		}
+
+		var source types.BlockHash
+		switch b := b.(type) {
+		case *blocks.ReceiveBlock:
+			source = b.Source
+		case *blocks.OpenBlock:
+			source = b.Source
+		case *blocks.UtxBlock:
+			source = b.LinkHash()
+		}
+
+		if source == send {
+			return l.rollback(pub, hash)
+		}
+
+		if _, ok := b.(*blocks.OpenBlock); ok {
+			break
+		}
+
+		hash = b.GetPrevious()
+	}
+
+	return nil, ErrReceiveNotFound
+}
+
+// balance returns the balance of the account after the block.
+func (l *Ledger) balance(hash types.BlockHash) (uint128.Uint128, error) {
+	sb, err := l.bs.GetSideband(hash)
+	if err != nil {
+		return uint128.Uint128{}, errors.Wrapf(err, "failed to fetch block %s", hash)
+	}
+
+	return sb.Balance, nil
+}
+
+// representative returns the representative of the account after the
+// block with the given hash. The walk back ends at the newest pruned
+// block, which keeps the representative.
+func (l *Ledger) representative(hash types.BlockHash) (types.PubKey, error) {
+	for {
+		b, err := l.bs.GetBlock(hash)
+		if err == blocks.ErrPruned {
+			rep, err := l.bs.PrunedRepresentative(hash)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to fetch block %s", hash)
+			}
+
+			return rep, nil
+		}
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to fetch block %s", hash)
+		}
+
+		switch b := b.(type) {
+		case *blocks.OpenBlock:
+			return b.Representative, nil
+		case *blocks.ChangeBlock:
+			return b.Representative, nil
+		case *blocks.UtxBlock:
+			return b.Representative, nil
+		}
+
+		hash = b.GetPrevious()
+	}
+}
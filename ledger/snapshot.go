@@ -0,0 +1,630 @@
+package ledger
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"hash"
+	"io"
+
+	"github.com/s1na/nano/account"
+	"github.com/s1na/nano/blocks"
+	"github.com/s1na/nano/store"
+	"github.com/s1na/nano/types"
+	"github.com/s1na/nano/types/uint128"
+
+	"github.com/golang/crypto/blake2b"
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// A snapshot starts with snapshotMagic and the format version, followed
+// by records and the blake2b-256 checksum of everything before it. Each
+// record is its type, the big-endian length of its payload and the
+// payload. Blocks come first, ordered so that every block follows the
+// blocks it depends on, then accounts, pending entries and weights.
+var snapshotMagic = []byte("nanoledger")
+
+const (
+	snapshotVersion = 1
+	checksumSize    = 32
+	// resetBatch is the most keys deleted in one transaction
+	// when discarding a failed import.
+	resetBatch = 1000
+)
+
+// ledgerPrefixes are the prefixes of the keys of all ledger data,
+// which a failed import deletes, along with the unchecked count.
+var ledgerPrefixes = []string{"block:", "root:", "conflict:", "pruned:", "unchecked:", "unchecked_count", "account:", "pending:", "weight:"}
+
+const (
+	recordBlock byte = iota + 1
+	recordAccount
+	recordPending
+	recordWeight
+)
+
+var (
+	ErrBadSnapshot      = errors.New("snapshot is malformed")
+	ErrSnapshotChecksum = errors.New("snapshot checksum mismatch")
+	ErrSnapshotNetwork  = errors.New("snapshot is of another network")
+	ErrSnapshotMismatch = errors.New("snapshot doesn't match its blocks")
+	ErrLedgerNotEmpty   = errors.New("ledger must be empty to import a snapshot")
+	ErrLedgerPruned     = errors.New("pruned ledgers can't be exported")
+)
+
+// Export writes a snapshot of all blocks, accounts, pending entries
+// and weights of the ledger to w, as of a single transaction. Pruned
+// ledgers lack the blocks to replay, and can't be exported.
+func (l *Ledger) Export(w io.Writer) error {
+	return l.view(func(l *Ledger) error {
+		pruned, err := l.bs.Pruned()
+		if err != nil {
+			return err
+		}
+
+		if pruned {
+			return ErrLedgerPruned
+		}
+
+		sw, err := newSnapshotWriter(w)
+		if err != nil {
+			return err
+		}
+
+		e := &exporter{l: l, sw: sw, heights: make(map[string]uint64)}
+		err = l.as.Iterate(func(a *account.Account) error {
+			return e.emit(a.Head)
+		})
+		if err != nil {
+			return err
+		}
+
+		err = l.as.Iterate(func(a *account.Account) error {
+			return sw.record(recordAccount, encodeSnapshotAccount(a))
+		})
+		if err != nil {
+			return err
+		}
+
+		err = l.ps.Iterate(func(dest types.PubKey, hash types.BlockHash, p *Pending) error {
+			v := make([]byte, 0, 64)
+			v = append(append(v, dest...), hash.Slice()...)
+			return sw.record(recordPending, append(v, encodePending(p)...))
+		})
+		if err != nil {
+			return err
+		}
+
+		err = l.ws.Iterate(func(rep types.PubKey, w uint128.Uint128) error {
+			v := append([]byte{}, rep...)
+			return sw.record(recordWeight, append(v, w.GetBytes()...))
+		})
+		if err != nil {
+			return err
+		}
+
+		return sw.close()
+	})
+}
+
+// Import loads a snapshot written by Export into the ledger, which must
+// not hold any block but the genesis. The checksum is verified before
+// anything is stored, and the blocks are then applied and validated as
+// if received from the network, after which the accounts, pending
+// entries and weights of the snapshot must match the resulting ledger.
+// The import is all or nothing: the records are all checked to be well
+// formed first, and the ledger is reset to the genesis if applying
+// them fails.
+func (l *Ledger) Import(r io.ReadSeeker) error {
+	size, err := r.Seek(0, io.SeekEnd)
+	if err != nil {
+		return err
+	}
+
+	body := size - checksumSize
+	if body < int64(len(snapshotMagic)+1) {
+		return ErrBadSnapshot
+	}
+
+	if err := verifyChecksum(r, body); err != nil {
+		return err
+	}
+
+	if err := readSnapshot(r, body, new(checker).record); err != nil {
+		return err
+	}
+
+	if err := l.Init(); err != nil {
+		return err
+	}
+
+	accounts, err := l.countAccounts()
+	if err != nil {
+		return err
+	}
+
+	if accounts != 1 {
+		return ErrLedgerNotEmpty
+	}
+
+	im := &importer{l: l}
+	err = readSnapshot(r, body, im.record)
+	if err == nil {
+		err = im.finish()
+	}
+
+	if err != nil {
+		if rerr := l.reset(); rerr != nil {
+			return errors.Wrapf(rerr, "failed resetting the ledger after import failed with: %s", err)
+		}
+
+		return err
+	}
+
+	log.WithFields(log.Fields{"blocks": im.blocks, "accounts": im.accounts}).Info("Imported ledger snapshot")
+
+	return nil
+}
+
+// readSnapshot calls fn with each record in the first size bytes
+// of r, after checking the snapshot header.
+func readSnapshot(r io.ReadSeeker, size int64, fn func(byte, []byte) error) error {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	br := bufio.NewReader(io.LimitReader(r, size))
+	header := make([]byte, len(snapshotMagic)+1)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return errors.Wrap(ErrBadSnapshot, err.Error())
+	}
+
+	if !bytes.Equal(header[:len(snapshotMagic)], snapshotMagic) || header[len(snapshotMagic)] != snapshotVersion {
+		return ErrBadSnapshot
+	}
+
+	var last byte
+	for {
+		t, payload, err := readRecord(br)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if t < last {
+			return errors.Wrap(ErrBadSnapshot, "records out of order")
+		}
+		last = t
+
+		if err := fn(t, payload); err != nil {
+			return err
+		}
+	}
+}
+
+// reset deletes all ledger data, in transactions of bounded
+// size, and stores the genesis block again.
+func (l *Ledger) reset() error {
+	for _, prefix := range ledgerPrefixes {
+		keys := l.store.GetPrefixKeys([]byte(prefix))
+		for len(keys) > 0 {
+			batch := keys
+			if len(batch) > resetBatch {
+				batch = batch[:resetBatch]
+			}
+			keys = keys[len(batch):]
+
+			err := l.store.Update(func(txn store.Txn) error {
+				for _, k := range batch {
+					if err := txn.Delete(k); err != nil {
+						return err
+					}
+				}
+
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return l.Init()
+}
+
+type snapshotWriter struct {
+	bw *bufio.Writer
+	h  hash.Hash
+	w  io.Writer
+}
+
+func newSnapshotWriter(w io.Writer) (*snapshotWriter, error) {
+	sw := new(snapshotWriter)
+
+	h, err := blake2b.New256(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	sw.bw = bufio.NewWriter(w)
+	sw.h = h
+	sw.w = io.MultiWriter(sw.bw, h)
+
+	if _, err := sw.w.Write(append(append([]byte{}, snapshotMagic...), snapshotVersion)); err != nil {
+		return nil, err
+	}
+
+	return sw, nil
+}
+
+func (sw *snapshotWriter) record(t byte, payload []byte) error {
+	var header [5]byte
+	header[0] = t
+	binary.BigEndian.PutUint32(header[1:], uint32(len(payload)))
+
+	if _, err := sw.w.Write(header[:]); err != nil {
+		return err
+	}
+
+	_, err := sw.w.Write(payload)
+	return err
+}
+
+// close appends the checksum and flushes the snapshot.
+func (sw *snapshotWriter) close() error {
+	if _, err := sw.bw.Write(sw.h.Sum(nil)); err != nil {
+		return err
+	}
+
+	return sw.bw.Flush()
+}
+
+func readRecord(r io.Reader) (byte, []byte, error) {
+	var header [5]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if err == io.ErrUnexpectedEOF {
+			return 0, nil, ErrBadSnapshot
+		}
+
+		return 0, nil, err
+	}
+
+	payload := make([]byte, binary.BigEndian.Uint32(header[1:]))
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return 0, nil, ErrBadSnapshot
+	}
+
+	return header[0], payload, nil
+}
+
+// verifyChecksum checks the checksum following the
+// first size bytes of r against the ones before it.
+func verifyChecksum(r io.ReadSeeker, size int64) error {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	h, err := blake2b.New256(nil)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.CopyN(h, r, size); err != nil {
+		return err
+	}
+
+	sum := make([]byte, checksumSize)
+	if _, err := io.ReadFull(r, sum); err != nil {
+		return err
+	}
+
+	if !bytes.Equal(sum, h.Sum(nil)) {
+		return ErrSnapshotChecksum
+	}
+
+	return nil
+}
+
+// exporter writes the blocks of the ledger such that the blocks
+// of each chain are in order and the sends they receive precede them.
+type exporter struct {
+	l  *Ledger
+	sw *snapshotWriter
+	// heights holds the height of the last block
+	// written for each account, keyed by address.
+	heights map[string]uint64
+}
+
+// emit writes the block with the given hash, preceded by
+// the blocks of its chain and the sends they receive
+// which haven't been written yet.
+func (e *exporter) emit(hash types.BlockHash) error {
+	sb, err := e.l.bs.GetSideband(hash)
+	if err != nil {
+		return errors.Wrapf(err, "failed to fetch block %s", hash)
+	}
+
+	addr := sb.Account.Address()
+	var chain []blocks.Block
+	for h, height := hash, sb.Height; height > e.heights[addr]; height-- {
+		b, err := e.l.bs.GetBlock(h)
+		if err != nil {
+			return errors.Wrapf(err, "failed to fetch block %s", h)
+		}
+
+		chain = append(chain, b)
+		h = b.GetPrevious()
+	}
+
+	for i := len(chain) - 1; i >= 0; i-- {
+		b := chain[i]
+		height := sb.Height - uint64(i)
+
+		// Emitting a source may have written this block already
+		if height <= e.heights[addr] {
+			continue
+		}
+
+		source, err := e.l.source(b)
+		if err != nil {
+			return err
+		}
+
+		if !source.IsZero() {
+			if err := e.emit(source); err != nil {
+				return err
+			}
+		}
+
+		id, _ := blocks.TypeId(b.Type())
+		data, err := blocks.MarshalBinary(b)
+		if err != nil {
+			return err
+		}
+
+		if err := e.sw.record(recordBlock, append([]byte{id}, data...)); err != nil {
+			return err
+		}
+
+		e.heights[addr] = height
+	}
+
+	return nil
+}
+
+// recordSizes are the payload sizes of the fixed size records.
+var recordSizes = map[byte]int{recordAccount: 152, recordPending: 112, recordWeight: 48}
+
+// checker checks the records of a snapshot are well formed,
+// and that its first block is the genesis of the network.
+type checker struct {
+	blocks int
+}
+
+func (c *checker) record(t byte, payload []byte) error {
+	switch t {
+	case recordBlock:
+		if len(payload) == 0 {
+			return ErrBadSnapshot
+		}
+
+		bt, ok := blocks.TypeFromId(payload[0])
+		if !ok {
+			return errors.Wrapf(ErrBadSnapshot, "unknown block type %d", payload[0])
+		}
+
+		b, err := blocks.UnmarshalBinary(bt, payload[1:])
+		if err != nil {
+			return errors.Wrap(ErrBadSnapshot, err.Error())
+		}
+
+		c.blocks++
+		if c.blocks == 1 && b.Hash() != blocks.GenesisBlock.Hash() {
+			return ErrSnapshotNetwork
+		}
+	case recordAccount, recordPending, recordWeight:
+		if len(payload) != recordSizes[t] {
+			return ErrBadSnapshot
+		}
+	default:
+		return errors.Wrapf(ErrBadSnapshot, "unknown record type %d", t)
+	}
+
+	return nil
+}
+
+// importer applies the records of a snapshot, and
+// counts them to check nothing is missing at the end.
+type importer struct {
+	l        *Ledger
+	blocks   int
+	accounts int
+	pendings int
+	weights  int
+}
+
+func (im *importer) record(t byte, payload []byte) error {
+	switch t {
+	case recordBlock:
+		return im.block(payload)
+	case recordAccount:
+		return im.account(payload)
+	case recordPending:
+		return im.pending(payload)
+	case recordWeight:
+		return im.weight(payload)
+	}
+
+	return errors.Wrapf(ErrBadSnapshot, "unknown record type %d", t)
+}
+
+func (im *importer) block(payload []byte) error {
+	if len(payload) == 0 {
+		return ErrBadSnapshot
+	}
+
+	t, ok := blocks.TypeFromId(payload[0])
+	if !ok {
+		return errors.Wrapf(ErrBadSnapshot, "unknown block type %d", payload[0])
+	}
+
+	b, err := blocks.UnmarshalBinary(t, payload[1:])
+	if err != nil {
+		return errors.Wrap(ErrBadSnapshot, err.Error())
+	}
+
+	im.blocks++
+	genesis := b.Hash() == blocks.GenesisBlock.Hash()
+	if im.blocks == 1 && !genesis {
+		return ErrSnapshotNetwork
+	}
+
+	if genesis {
+		return nil
+	}
+
+	if err := im.l.update(func(l *Ledger) error { return l.addBlock(b) }); err != nil {
+		return errors.Wrapf(err, "invalid block %s", b.Hash())
+	}
+
+	return nil
+}
+
+func (im *importer) account(payload []byte) error {
+	if len(payload) != 152 {
+		return ErrBadSnapshot
+	}
+
+	pub := types.PubKeyFromSlice(payload[:32])
+	im.accounts++
+
+	return im.l.update(func(l *Ledger) error {
+		acc, err := l.as.GetAccount(pub)
+		if err != nil {
+			return errors.Wrapf(ErrSnapshotMismatch, "account %s: %s", pub.Address(), err)
+		}
+
+		if !bytes.Equal(acc.Head.Slice(), payload[32:64]) ||
+			!bytes.Equal(acc.Open.Slice(), payload[64:96]) ||
+			!bytes.Equal(acc.Rep, payload[96:128]) ||
+			!acc.Balance.Equal(uint128.FromBytes(payload[128:144])) {
+			return errors.Wrapf(ErrSnapshotMismatch, "account %s", pub.Address())
+		}
+
+		sb, err := l.bs.GetSideband(acc.Head)
+		if err != nil {
+			return err
+		}
+
+		height := binary.BigEndian.Uint64(payload[144:])
+		if height > sb.Height {
+			return errors.Wrapf(ErrSnapshotMismatch, "confirmation height of account %s", pub.Address())
+		}
+
+		acc.ConfirmationHeight = height
+
+		return l.as.SetAccount(acc)
+	})
+}
+
+func (im *importer) pending(payload []byte) error {
+	if len(payload) != 112 {
+		return ErrBadSnapshot
+	}
+
+	dest := types.PubKeyFromSlice(payload[:32])
+	hash := types.BlockHashFromSlice(payload[32:64])
+	p, err := im.l.ps.GetPending(dest, hash)
+	if err != nil {
+		return errors.Wrapf(ErrSnapshotMismatch, "pending %s: %s", hash, err)
+	}
+
+	if !bytes.Equal(encodePending(p), payload[64:]) {
+		return errors.Wrapf(ErrSnapshotMismatch, "pending %s", hash)
+	}
+
+	im.pendings++
+
+	return nil
+}
+
+func (im *importer) weight(payload []byte) error {
+	if len(payload) != 48 {
+		return ErrBadSnapshot
+	}
+
+	rep := types.PubKeyFromSlice(payload[:32])
+	w, err := im.l.ws.GetWeight(rep)
+	if err != nil {
+		return err
+	}
+
+	if !w.Equal(uint128.FromBytes(payload[32:])) {
+		return errors.Wrapf(ErrSnapshotMismatch, "weight of %s", rep.Address())
+	}
+
+	im.weights++
+
+	return nil
+}
+
+// finish checks the ledger holds no more accounts,
+// pending entries or weights than the snapshot.
+func (im *importer) finish() error {
+	accounts, err := im.l.countAccounts()
+	if err != nil {
+		return err
+	}
+
+	var pendings, weights int
+	err = im.l.ps.Iterate(func(types.PubKey, types.BlockHash, *Pending) error {
+		pendings++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = im.l.ws.Iterate(func(types.PubKey, uint128.Uint128) error {
+		weights++
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if accounts != im.accounts || pendings != im.pendings || weights != im.weights {
+		return errors.Wrap(ErrSnapshotMismatch, "missing records")
+	}
+
+	return nil
+}
+
+func (l *Ledger) countAccounts() (int, error) {
+	n := 0
+	err := l.as.Iterate(func(*account.Account) error {
+		n++
+		return nil
+	})
+
+	return n, err
+}
+
+// A snapshot account is its public key, head, open block,
+// representative, balance and confirmation height.
+func encodeSnapshotAccount(a *account.Account) []byte {
+	v := make([]byte, 0, 152)
+	v = append(v, a.PublicKey...)
+	v = append(v, a.Head.Slice()...)
+	v = append(v, a.Open.Slice()...)
+	v = append(v, a.Rep...)
+	v = append(v, a.Balance.GetBytes()...)
+
+	var height [8]byte
+	binary.BigEndian.PutUint64(height[:], a.ConfirmationHeight)
+
+	return append(v, height[:]...)
+}
@@ -0,0 +1,517 @@
+package ledger
+
+import (
+	"fmt"
+
+	"github.com/s1na/nano/account"
+	"github.com/s1na/nano/blocks"
+	"github.com/s1na/nano/store"
+	"github.com/s1na/nano/types"
+	"github.com/s1na/nano/types/uint128"
+
+	"github.com/pkg/errors"
+)
+
+// Discrepancy is an inconsistency between the
+// stored blocks and the data derived from them.
+type Discrepancy struct {
+	Account types.PubKey
+	Hash    types.BlockHash
+	Problem string
+	// Repaired is set when the derived data
+	// has been rewritten to match the blocks.
+	Repaired bool
+}
+
+func (d *Discrepancy) String() string {
+	s := d.Problem
+	if !d.Hash.IsZero() {
+		s = fmt.Sprintf("block %s: %s", d.Hash, s)
+	}
+
+	if len(d.Account) != 0 {
+		s = fmt.Sprintf("account %s: %s", d.Account.Address(), s)
+	}
+
+	if d.Repaired {
+		s += " (repaired)"
+	}
+
+	return s
+}
+
+// Verify walks every account chain and checks the hash, work and
+// signature of its blocks, and that the sidebands, accounts, pending
+// entries and weights match the blocks. If repair is set, those are
+// rewritten from the blocks, which can't be repaired themselves. The
+// parts of pruned chains are trusted as stored, as is the amount of a
+// send following them.
+func (l *Ledger) Verify(repair bool) ([]*Discrepancy, error) {
+	v := &verifier{
+		repair:   repair,
+		sends:    make(map[types.BlockHash]*pendingSend),
+		received: make(map[types.BlockHash]bool),
+		weights:  make(map[string]uint128.Uint128),
+		reps:     make(map[string]types.PubKey),
+	}
+
+	fn := func(l *Ledger) error {
+		v.l = l
+
+		accounts, err := l.as.GetAccounts()
+		if err != nil {
+			return err
+		}
+
+		for _, acc := range accounts {
+			if err := v.verifyAccount(acc); err != nil {
+				return err
+			}
+		}
+
+		if err := v.verifyPending(); err != nil {
+			return err
+		}
+
+		return v.verifyWeights()
+	}
+
+	var err error
+	if repair {
+		err = l.update(fn)
+	} else {
+		err = l.view(fn)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return v.found, nil
+}
+
+type pendingSend struct {
+	dest types.PubKey
+	p    Pending
+	// unknown is set for the sends whose amount is unknown,
+	// as the block before them has been pruned.
+	unknown bool
+}
+
+// matches reports whether the pending entry s is the one expected.
+func (s *pendingSend) matches(expected *pendingSend) bool {
+	return s.dest.Equal(expected.dest) && s.p.Source.Equal(expected.p.Source) && (expected.unknown || s.p.Amount.Equal(expected.p.Amount))
+}
+
+type verifier struct {
+	l      *Ledger
+	repair bool
+	found  []*Discrepancy
+	// sends holds the sends found in the chains, and
+	// received the ones which have been received.
+	sends    map[types.BlockHash]*pendingSend
+	received map[types.BlockHash]bool
+	// weights holds the sum of the balances delegated
+	// to each representative, keyed by address.
+	weights map[string]uint128.Uint128
+	reps    map[string]types.PubKey
+}
+
+func (v *verifier) report(pub types.PubKey, hash types.BlockHash, repaired bool, format string, args ...interface{}) {
+	v.found = append(v.found, &Discrepancy{
+		Account:  pub,
+		Hash:     hash,
+		Problem:  fmt.Sprintf(format, args...),
+		Repaired: repaired,
+	})
+}
+
+// chain returns the blocks of the chain of acc from the oldest stored
+// one onwards, and whether the blocks preceding it have been pruned.
+func (v *verifier) chain(acc *account.Account) ([]blocks.Block, bool, error) {
+	var chain []blocks.Block
+	for hash := acc.Head; ; {
+		b, err := v.l.bs.GetBlock(hash)
+		if err == blocks.ErrPruned && len(chain) > 0 {
+			reverse(chain)
+			return chain, true, nil
+		}
+		if err != nil {
+			return nil, false, err
+		}
+
+		if b.Hash() != hash {
+			v.report(acc.PublicKey, hash, false, "block stored under another hash %s", b.Hash())
+		}
+
+		chain = append(chain, b)
+		if ub, ok := b.(*blocks.UtxBlock); b.Type() == blocks.Open || ok && ub.IsOpen() {
+			reverse(chain)
+			return chain, false, nil
+		}
+
+		hash = b.GetPrevious()
+	}
+}
+
+func (v *verifier) verifyAccount(acc *account.Account) error {
+	pub := acc.PublicKey
+	chain, pruned, err := v.chain(acc)
+	if err != nil {
+		if err == store.ErrKeyNotFound {
+			v.report(pub, types.BlockHash{}, false, "chain is missing blocks")
+			return nil
+		}
+
+		return err
+	}
+
+	var balance uint128.Uint128
+	var height uint64
+	rep := acc.Rep
+	epoch := acc.Epoch
+	if !pruned {
+		epoch = 0
+	}
+
+	for i, b := range chain {
+		hash := b.Hash()
+		if !blocks.ValidateBlockWork(b) {
+			v.report(pub, hash, false, "invalid work")
+		}
+
+		signer := pub
+		if ub, ok := b.(*blocks.UtxBlock); ok {
+			signer = ub.Signer()
+		}
+
+		if err := blocks.VerifySignature(b, signer); err != nil {
+			v.report(pub, hash, false, "invalid signature")
+		}
+
+		sb, err := v.l.bs.GetSideband(hash)
+		if err != nil {
+			return err
+		}
+
+		// The first block after the pruned ones is the base of the rest
+		if pruned && i == 0 {
+			balance, height = sb.Balance, sb.Height
+			if err := v.verifyBase(pub, b); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		previous := balance
+		height++
+
+		switch b := b.(type) {
+		case *blocks.SendBlock:
+			balance = b.Balance
+			v.addSend(pub, hash, b.Destination, previous, balance)
+		case *blocks.ReceiveBlock:
+			amount, err := v.receive(pub, hash, b.Source, sb.Balance.Sub(previous))
+			if err != nil {
+				return err
+			}
+
+			balance = previous.Add(amount)
+		case *blocks.OpenBlock:
+			rep = b.Representative
+			if hash == blocks.GenesisBlock.Hash() {
+				balance = blocks.GenesisAmount
+				break
+			}
+
+			if balance, err = v.receive(pub, hash, b.Source, sb.Balance); err != nil {
+				return err
+			}
+		case *blocks.ChangeBlock:
+			rep = b.Representative
+		case *blocks.UtxBlock:
+			rep = b.Representative
+			balance = b.Balance
+			if b.IsEpoch() {
+				epoch = blocks.Epoch1
+			} else if b.IsSend(previous) {
+				v.addSend(pub, hash, b.Link, previous, balance)
+			} else if b.IsReceive(previous) {
+				amount, err := v.receive(pub, hash, b.LinkHash(), balance.Sub(previous))
+				if err != nil {
+					return err
+				}
+
+				if !amount.Equal(balance.Sub(previous)) {
+					v.report(pub, hash, false, "received amount doesn't match the send")
+				}
+			}
+		}
+
+		var successor types.BlockHash
+		if i < len(chain)-1 {
+			successor = chain[i+1].Hash()
+		}
+
+		if !sb.Account.Equal(pub) || sb.Height != height || !sb.Balance.Equal(balance) || sb.Successor != successor {
+			if v.repair {
+				sb.Account, sb.Height, sb.Balance, sb.Successor = pub, height, balance, successor
+				if err := v.l.bs.SetSideband(hash, sb); err != nil {
+					return err
+				}
+			}
+
+			v.report(pub, hash, v.repair, "sideband doesn't match the chain")
+		}
+	}
+
+	dirty := false
+	if !pruned && acc.Open != chain[0].Hash() {
+		v.report(pub, types.BlockHash{}, v.repair, "open block is %s instead of %s", acc.Open, chain[0].Hash())
+		acc.Open = chain[0].Hash()
+		dirty = true
+	}
+
+	if !acc.Balance.Equal(balance) {
+		v.report(pub, types.BlockHash{}, v.repair, "balance is %s instead of %s", acc.Balance, balance)
+		acc.Balance = balance
+		dirty = true
+	}
+
+	if !acc.Rep.Equal(rep) {
+		v.report(pub, types.BlockHash{}, v.repair, "representative is %s instead of %s", acc.Rep.Address(), rep.Address())
+		acc.Rep = rep
+		dirty = true
+	}
+
+	if acc.Epoch != epoch {
+		v.report(pub, types.BlockHash{}, v.repair, "epoch is %d instead of %d", acc.Epoch, epoch)
+		acc.Epoch = epoch
+		dirty = true
+	}
+
+	if acc.ConfirmationHeight > height {
+		v.report(pub, types.BlockHash{}, v.repair, "confirmation height %d is above the head", acc.ConfirmationHeight)
+		acc.ConfirmationHeight = height
+		dirty = true
+	}
+
+	if dirty && v.repair {
+		if err := v.l.as.SetAccount(acc); err != nil {
+			return err
+		}
+	}
+
+	v.weights[rep.Address()] = v.weights[rep.Address()].Add(balance)
+	v.reps[rep.Address()] = rep
+
+	return nil
+}
+
+// verifyBase records what the base block of a pruned chain sends or
+// receives. The balance before it is unknown, so the amount of a send
+// is taken from its pending entry, and a universal block is told to be
+// a send by its pending entry, or a receive by its source.
+func (v *verifier) verifyBase(pub types.PubKey, b blocks.Block) error {
+	hash := b.Hash()
+	var source types.BlockHash
+	switch b := b.(type) {
+	case *blocks.SendBlock:
+		v.sends[hash] = &pendingSend{dest: b.Destination, p: Pending{Source: pub}, unknown: true}
+		return nil
+	case *blocks.ReceiveBlock:
+		source = b.Source
+	case *blocks.OpenBlock:
+		source = b.Source
+	case *blocks.UtxBlock:
+		if b.IsEpoch() || b.LinkHash().IsZero() {
+			return nil
+		}
+
+		_, err := v.l.ps.GetPending(b.Link, hash)
+		if err == nil {
+			v.sends[hash] = &pendingSend{dest: b.Link, p: Pending{Source: pub}, unknown: true}
+			return nil
+		}
+		if err != store.ErrKeyNotFound {
+			return err
+		}
+
+		// A change, or a send which has been received, links no block
+		if _, err := v.l.bs.GetBlock(b.LinkHash()); err != nil && err != blocks.ErrPruned {
+			if err == store.ErrKeyNotFound {
+				return nil
+			}
+
+			return err
+		}
+
+		source = b.LinkHash()
+	}
+
+	if source.IsZero() || hash == blocks.GenesisBlock.Hash() {
+		return nil
+	}
+
+	_, err := v.receive(pub, hash, source, uint128.Uint128{})
+	return err
+}
+
+func (v *verifier) addSend(pub types.PubKey, hash types.BlockHash, dest types.PubKey, previous, balance uint128.Uint128) {
+	if balance.Compare(previous) > 0 {
+		v.report(pub, hash, false, "send increases the balance")
+		return
+	}
+
+	v.sends[hash] = &pendingSend{dest: dest, p: Pending{Source: pub, Amount: previous.Sub(balance)}}
+}
+
+// receive checks source is a send to pub, and returns its amount. The
+// amounts of pruned sends are unknown, for which stored is trusted.
+func (v *verifier) receive(pub types.PubKey, hash, source types.BlockHash, stored uint128.Uint128) (uint128.Uint128, error) {
+	if v.received[source] {
+		v.report(pub, hash, false, "send %s has been received before", source)
+	}
+	v.received[source] = true
+
+	b, err := v.l.bs.GetBlock(source)
+	if err != nil {
+		if err == blocks.ErrPruned {
+			return stored, nil
+		}
+
+		if err == store.ErrKeyNotFound {
+			v.report(pub, hash, false, "source %s is missing", source)
+			return stored, nil
+		}
+
+		return uint128.Uint128{}, err
+	}
+
+	var dest types.PubKey
+	switch b := b.(type) {
+	case *blocks.SendBlock:
+		dest = b.Destination
+	case *blocks.UtxBlock:
+		dest = b.Link
+	}
+
+	if !dest.Equal(pub) {
+		v.report(pub, hash, false, "source %s is not a send to this account", source)
+		return stored, nil
+	}
+
+	amount, err := v.l.sentAmount(source)
+	if err != nil {
+		if errors.Cause(err) == blocks.ErrPruned {
+			return stored, nil
+		}
+
+		return uint128.Uint128{}, err
+	}
+
+	return amount, nil
+}
+
+// verifyPending checks that the sends which haven't
+// been received are exactly the pending entries.
+func (v *verifier) verifyPending() error {
+	stored := make(map[types.BlockHash]*pendingSend)
+	err := v.l.ps.Iterate(func(dest types.PubKey, hash types.BlockHash, p *Pending) error {
+		stored[hash] = &pendingSend{dest: dest, p: *p}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for hash, s := range stored {
+		expected, ok := v.sends[hash]
+		if !ok {
+			// Pending entries outlive the sends being pruned
+			_, err := v.l.bs.GetBlock(hash)
+			if err == blocks.ErrPruned {
+				continue
+			}
+
+			if err != nil && err != store.ErrKeyNotFound {
+				return err
+			}
+		}
+
+		if ok && !v.received[hash] && s.matches(expected) {
+			continue
+		}
+
+		if v.repair {
+			if err := v.l.ps.DeletePending(s.dest, hash); err != nil {
+				return err
+			}
+		}
+
+		v.report(s.dest, hash, v.repair, "pending entry doesn't match a send which hasn't been received")
+	}
+
+	for hash, s := range v.sends {
+		if v.received[hash] {
+			continue
+		}
+
+		if cur, ok := stored[hash]; ok && cur.matches(s) {
+			continue
+		}
+
+		if s.unknown {
+			v.report(s.dest, hash, false, "pending entry is missing, with an unknown amount")
+			continue
+		}
+
+		if v.repair {
+			p := s.p
+			if err := v.l.ps.SetPending(s.dest, hash, &p); err != nil {
+				return err
+			}
+		}
+
+		v.report(s.dest, hash, v.repair, "pending entry is missing")
+	}
+
+	return nil
+}
+
+// verifyWeights checks the weight of each representative
+// is the sum of the balances of the accounts delegating to it.
+func (v *verifier) verifyWeights() error {
+	stored := make(map[string]uint128.Uint128)
+	err := v.l.ws.Iterate(func(rep types.PubKey, w uint128.Uint128) error {
+		stored[rep.Address()] = w
+		v.reps[rep.Address()] = rep
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	for addr, rep := range v.reps {
+		w, expected := stored[addr], v.weights[addr]
+		if w.Equal(expected) {
+			continue
+		}
+
+		if v.repair {
+			if err := v.l.ws.setWeight(rep, expected); err != nil {
+				return err
+			}
+		}
+
+		v.report(rep, types.BlockHash{}, v.repair, "weight is %s instead of %s", w, expected)
+	}
+
+	return nil
+}
+
+func reverse(chain []blocks.Block) {
+	for i, j := 0, len(chain)-1; i < j; i, j = i+1, j-1 {
+		chain[i], chain[j] = chain[j], chain[i]
+	}
+}
@@ -0,0 +1,107 @@
+package ledger
+
+import (
+	"github.com/s1na/nano/store"
+	"github.com/s1na/nano/types"
+	"github.com/s1na/nano/types/uint128"
+)
+
+const weightPrefix = "weight:"
+
+// WeightStore keeps the voting weight of each representative,
+// which is the sum of the balances of the accounts delegating
+// to it.
+type WeightStore struct {
+	s store.ReadWriter
+}
+
+func NewWeightStore(store store.ReadWriter) *WeightStore {
+	s := new(WeightStore)
+
+	s.s = store
+
+	return s
+}
+
+// GetWeight returns the weight of rep, which is zero
+// for accounts no one delegates to.
+func (s *WeightStore) GetWeight(rep types.PubKey) (uint128.Uint128, error) {
+	v, err := s.s.Get(weightKey(rep))
+	if err != nil {
+		if err == store.ErrKeyNotFound {
+			return uint128.Uint128{}, nil
+		}
+
+		return uint128.Uint128{}, err
+	}
+
+	if len(v) != 16 {
+		return uint128.Uint128{}, store.ErrCorrupt
+	}
+
+	return uint128.FromBytes(v), nil
+}
+
+func (s *WeightStore) AddWeight(rep types.PubKey, amount uint128.Uint128) error {
+	w, err := s.GetWeight(rep)
+	if err != nil {
+		return err
+	}
+
+	return s.setWeight(rep, w.Add(amount))
+}
+
+func (s *WeightStore) SubWeight(rep types.PubKey, amount uint128.Uint128) error {
+	w, err := s.GetWeight(rep)
+	if err != nil {
+		return err
+	}
+
+	return s.setWeight(rep, w.Sub(amount))
+}
+
+// GetWeights returns the weights of all
+// representatives, keyed by their address.
+func (s *WeightStore) GetWeights() (map[string]uint128.Uint128, error) {
+	res, err := s.s.GetPrefixValues([]byte(weightPrefix))
+	if err != nil {
+		return nil, err
+	}
+
+	weights := make(map[string]uint128.Uint128)
+	for k, v := range res {
+		if len(v) != 16 {
+			return nil, store.ErrCorrupt
+		}
+
+		rep := types.PubKeyFromSlice([]byte(k)[len(weightPrefix):])
+		weights[rep.Address()] = uint128.FromBytes(v)
+	}
+
+	return weights, nil
+}
+
+// Iterate calls fn for the weight of each
+// representative, ordered by public key.
+func (s *WeightStore) Iterate(fn func(rep types.PubKey, w uint128.Uint128) error) error {
+	return s.s.Iterate([]byte(weightPrefix), func(k, v []byte) error {
+		if len(v) != 16 {
+			return store.ErrCorrupt
+		}
+
+		return fn(types.PubKeyFromSlice(k[len(weightPrefix):]), uint128.FromBytes(v))
+	})
+}
+
+func (s *WeightStore) setWeight(rep types.PubKey, w uint128.Uint128) error {
+	// Drop representatives no one delegates to anymore
+	if w.Equal(uint128.Uint128{}) {
+		return s.s.Delete(weightKey(rep))
+	}
+
+	return s.s.Set(weightKey(rep), w.GetBytes())
+}
+
+func weightKey(rep types.PubKey) []byte {
+	return append([]byte(weightPrefix), rep...)
+}
@@ -9,11 +9,11 @@ import (
 )
 
 const (
-	sendSize    = 32 + 32 + 16 + 64 + 8
-	openSize    = 32 + 32 + 32 + 64 + 8
-	changeSize  = 32 + 32 + 64 + 8
-	receiveSize = 32 + 32 + 64 + 8
-	utxSize     = 32 + 32 + 32 + 16 + 16 + 32 + 64 + 8
+	sendSize    = blocks.SendSize
+	openSize    = blocks.OpenSize
+	changeSize  = blocks.ChangeSize
+	receiveSize = blocks.ReceiveSize
+	utxSize     = blocks.UtxSize
 )
 
 type Block struct {
@@ -25,7 +25,6 @@ type Block struct {
 	Account        [32]byte
 	Link           [32]byte
 	Balance        [16]byte
-	Amount         [16]byte
 	Signature      [64]byte
 	Work           [8]byte
 }
@@ -73,7 +72,6 @@ func (m *Block) ToBlock() blocks.Block {
 			types.BlockHash(m.Previous),
 			types.PubKey(m.Representative[:]),
 			uint128.FromBytes(m.Balance[:]),
-			uint128.FromBytes(m.Amount[:]),
 			types.PubKey(m.Link[:]),
 			common,
 		}
@@ -83,93 +81,68 @@ func (m *Block) ToBlock() blocks.Block {
 	}
 }
 
-func (m *Block) Unmarshal(data []byte) error {
-	invalidErr := errors.New("invalid block")
-
-	switch m.Type {
-	case sendBlock:
-		if len(data) != sendSize {
-			return invalidErr
-		}
-
-		copy(m.Previous[:], data[:32])
-		copy(m.Destination[:], data[32:64])
-		copy(m.Balance[:], data[64:80])
-		copy(m.Signature[:], data[80:144])
-		copy(m.Work[:], data[144:152])
-	case openBlock:
-		if len(data) != openSize {
-			return invalidErr
-		}
-
-		copy(m.Source[:], data[:32])
-		copy(m.Representative[:], data[32:64])
-		copy(m.Account[:], data[64:96])
-		copy(m.Signature[:], data[96:160])
-		copy(m.Work[:], data[160:168])
-	case changeBlock:
-		if len(data) != changeSize {
-			return invalidErr
-		}
+// FromBlock converts b to its wire representation.
+func FromBlock(b blocks.Block) *Block {
+	m := new(Block)
+	m.Signature = b.GetSignature()
+	m.Work = b.GetWork()
+
+	switch b := b.(type) {
+	case *blocks.SendBlock:
+		m.Type = sendBlock
+		m.Previous = b.Previous
+		copy(m.Destination[:], b.Destination)
+		copy(m.Balance[:], b.Balance.GetBytes())
+	case *blocks.OpenBlock:
+		m.Type = openBlock
+		m.Source = b.Source
+		copy(m.Representative[:], b.Representative)
+		copy(m.Account[:], b.Account)
+	case *blocks.ChangeBlock:
+		m.Type = changeBlock
+		m.Previous = b.Previous
+		copy(m.Representative[:], b.Representative)
+	case *blocks.ReceiveBlock:
+		m.Type = receiveBlock
+		m.Previous = b.Previous
+		m.Source = b.Source
+	case *blocks.UtxBlock:
+		m.Type = utxBlock
+		copy(m.Account[:], b.Account)
+		m.Previous = b.Previous
+		copy(m.Representative[:], b.Representative)
+		copy(m.Balance[:], b.Balance.GetBytes())
+		copy(m.Link[:], b.Link)
+	default:
+		m.Type = invalidBlock
+	}
 
-		copy(m.Previous[:], data[:32])
-		copy(m.Representative[:], data[32:64])
-		copy(m.Signature[:], data[64:128])
-		copy(m.Work[:], data[128:136])
-	case receiveBlock:
-		if len(data) != receiveSize {
-			return invalidErr
-		}
+	return m
+}
 
-		copy(m.Previous[:], data[:32])
-		copy(m.Source[:], data[32:64])
-		copy(m.Signature[:], data[64:128])
-		copy(m.Work[:], data[128:136])
-	case utxBlock:
-		if len(data) != utxSize {
-			return invalidErr
-		}
+// Unmarshal decodes a block of type m.Type from its wire layout.
+func (m *Block) Unmarshal(data []byte) error {
+	t, ok := blocks.TypeFromId(m.Type)
+	if !ok {
+		return errors.New("unknown block type")
+	}
 
-		copy(m.Account[:], data[:32])
-		copy(m.Previous[:], data[32:64])
-		copy(m.Representative[:], data[64:96])
-		copy(m.Balance[:], data[96:112])
-		copy(m.Amount[:], data[112:128])
-		copy(m.Link[:], data[128:160])
+	b, err := blocks.UnmarshalBinary(t, data)
+	if err != nil {
+		return err
 	}
 
+	*m = *FromBlock(b)
+
 	return nil
 }
 
+// Marshal encodes the block in its wire layout, without the type.
 func (m *Block) Marshal() ([]byte, error) {
-	data := make([]byte, 0, 136)
-
-	switch m.Type {
-	case sendBlock:
-		data = append(data, m.Previous[:]...)
-		data = append(data, m.Destination[:]...)
-		data = append(data, m.Balance[:]...)
-	case openBlock:
-		data = append(data, m.Source[:]...)
-		data = append(data, m.Representative[:]...)
-		data = append(data, m.Account[:]...)
-	case changeBlock:
-		data = append(data, m.Previous[:]...)
-		data = append(data, m.Representative[:]...)
-	case receiveBlock:
-		data = append(data, m.Previous[:]...)
-		data = append(data, m.Source[:]...)
-	case utxBlock:
-		data = append(data, m.Account[:]...)
-		data = append(data, m.Previous[:]...)
-		data = append(data, m.Representative[:]...)
-		data = append(data, m.Balance[:]...)
-		data = append(data, m.Amount[:]...)
-		data = append(data, m.Link[:]...)
+	b := m.ToBlock()
+	if b == nil {
+		return nil, errors.New("unknown block type")
 	}
 
-	data = append(data, m.Signature[:]...)
-	data = append(data, m.Work[:]...)
-
-	return data, nil
+	return blocks.MarshalBinary(b)
 }
@@ -0,0 +1,265 @@
+package network
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"net"
+	"time"
+
+	"github.com/s1na/nano/blocks"
+	"github.com/s1na/nano/types"
+
+	"github.com/pkg/errors"
+)
+
+// BootstrapTimeout bounds connecting to a bootstrap server,
+// and each read from it.
+const BootstrapTimeout = 30 * time.Second
+
+var ErrBadBlockType = errors.New("unexpected block type")
+
+// FrontierReq asks for the head block of the accounts,
+// from the start account on.
+type FrontierReq struct {
+	Start [32]byte
+	Age   uint32
+	Count uint32
+}
+
+func (m *FrontierReq) Marshal() ([]byte, error) {
+	data := make([]byte, 40)
+
+	copy(data[:32], m.Start[:])
+	binary.LittleEndian.PutUint32(data[32:36], m.Age)
+	binary.LittleEndian.PutUint32(data[36:40], m.Count)
+
+	return data, nil
+}
+
+func (m *FrontierReq) Unmarshal(data []byte) error {
+	if len(data) != 40 {
+		return errors.New("frontier request has invalid length")
+	}
+
+	copy(m.Start[:], data[:32])
+	m.Age = binary.LittleEndian.Uint32(data[32:36])
+	m.Count = binary.LittleEndian.Uint32(data[36:40])
+
+	return nil
+}
+
+// BulkPull asks for the chain of the start account, from
+// its head back to the end block, which isn't included.
+// A zero end asks for the whole chain.
+type BulkPull struct {
+	Start [32]byte
+	End   [32]byte
+}
+
+func (m *BulkPull) Marshal() ([]byte, error) {
+	data := make([]byte, 64)
+
+	copy(data[:32], m.Start[:])
+	copy(data[32:], m.End[:])
+
+	return data, nil
+}
+
+func (m *BulkPull) Unmarshal(data []byte) error {
+	if len(data) != 64 {
+		return errors.New("bulk pull has invalid length")
+	}
+
+	copy(m.Start[:], data[:32])
+	copy(m.End[:], data[32:])
+
+	return nil
+}
+
+// BulkPush announces a stream of blocks pushed to the
+// peer, which has no body of its own.
+type BulkPush struct{}
+
+func (m *BulkPush) Marshal() ([]byte, error) {
+	return nil, nil
+}
+
+func (m *BulkPush) Unmarshal(data []byte) error {
+	return nil
+}
+
+// BootstrapClient is a tcp connection to the bootstrap server of a
+// peer. Requests are made one at a time, each reading its response
+// to the end before the next one is sent.
+type BootstrapClient struct {
+	conn net.Conn
+	r    *bufio.Reader
+}
+
+// DialBootstrap connects to the bootstrap server at addr,
+// which listens on the peering port over tcp.
+func DialBootstrap(addr string) (*BootstrapClient, error) {
+	conn, err := net.DialTimeout("tcp", addr, BootstrapTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	c := new(BootstrapClient)
+	c.conn = conn
+	c.r = bufio.NewReader(conn)
+
+	return c, nil
+}
+
+func (c *BootstrapClient) Close() error {
+	return c.conn.Close()
+}
+
+// Frontiers requests the head block of every account from start on,
+// in the order of the accounts, calling fn with each of them.
+func (c *BootstrapClient) Frontiers(start types.PubKey, fn func(types.PubKey, types.BlockHash) error) error {
+	m := &FrontierReq{Age: ^uint32(0), Count: ^uint32(0)}
+	copy(m.Start[:], start)
+	if err := c.request(msgFrontierReq, m); err != nil {
+		return err
+	}
+
+	buf := make([]byte, 64)
+	for {
+		if err := c.read(buf); err != nil {
+			return errors.Wrap(err, "failed reading frontier")
+		}
+
+		// The response ends with a zero entry
+		if types.BlockHashFromSlice(buf[:32]).IsZero() {
+			return nil
+		}
+
+		if err := fn(types.PubKeyFromSlice(buf[:32]), types.BlockHashFromSlice(buf[32:])); err != nil {
+			return err
+		}
+	}
+}
+
+// BulkPull requests the blocks of the account chain from its head back
+// to end, which isn't included, calling fn with each of them, newest
+// first. A zero end pulls the whole chain.
+func (c *BootstrapClient) BulkPull(account types.PubKey, end types.BlockHash, fn func(blocks.Block) error) error {
+	m := &BulkPull{End: end}
+	copy(m.Start[:], account)
+	if err := c.request(msgBulkPull, m); err != nil {
+		return err
+	}
+
+	for {
+		c.conn.SetReadDeadline(time.Now().Add(BootstrapTimeout))
+		b, err := readBlock(c.r)
+		if err != nil {
+			return errors.Wrap(err, "failed reading pulled block")
+		}
+
+		if b == nil {
+			return nil
+		}
+
+		if err := fn(b); err != nil {
+			return err
+		}
+	}
+}
+
+// BulkPush uploads the blocks to the peer, which is
+// missing them, in the order they are given.
+func (c *BootstrapClient) BulkPush(bs []blocks.Block) error {
+	if err := c.request(msgBulkPush, new(BulkPush)); err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(c.conn)
+	for _, b := range bs {
+		m := FromBlock(b)
+		data, err := m.Marshal()
+		if err != nil {
+			return err
+		}
+
+		c.conn.SetWriteDeadline(time.Now().Add(BootstrapTimeout))
+		if _, err := w.Write(append([]byte{m.Type}, data...)); err != nil {
+			return errors.Wrap(err, "failed pushing block")
+		}
+	}
+
+	if err := w.WriteByte(notABlock); err != nil {
+		return err
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(BootstrapTimeout))
+	return w.Flush()
+}
+
+func (c *BootstrapClient) request(t byte, body MessagePart) error {
+	data, err := NewMessage(t, body).Marshal()
+	if err != nil {
+		return err
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(BootstrapTimeout))
+	_, err = c.conn.Write(data)
+	return err
+}
+
+func (c *BootstrapClient) read(buf []byte) error {
+	c.conn.SetReadDeadline(time.Now().Add(BootstrapTimeout))
+	_, err := io.ReadFull(c.r, buf)
+	return err
+}
+
+// readBlock reads a block prefixed with its type, returning
+// nil for the not a block type which ends a stream of blocks.
+func readBlock(r io.Reader) (blocks.Block, error) {
+	t := make([]byte, 1)
+	if _, err := io.ReadFull(r, t); err != nil {
+		return nil, err
+	}
+
+	if t[0] == notABlock {
+		return nil, nil
+	}
+
+	size := blockSize(t[0])
+	if size == 0 {
+		return nil, ErrBadBlockType
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, err
+	}
+
+	m := &Block{Type: t[0]}
+	if err := m.Unmarshal(data); err != nil {
+		return nil, err
+	}
+
+	return m.ToBlock(), nil
+}
+
+// blockSize returns the size of the blocks of type t
+// on the wire, or zero if there's no such type.
+func blockSize(t byte) int {
+	switch t {
+	case sendBlock:
+		return sendSize
+	case receiveBlock:
+		return receiveSize
+	case openBlock:
+		return openSize
+	case changeBlock:
+		return changeSize
+	case utxBlock:
+		return utxSize
+	}
+
+	return 0
+}
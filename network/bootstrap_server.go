@@ -0,0 +1,285 @@
+package network
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/s1na/nano/blocks"
+	"github.com/s1na/nano/types"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// MaxBootstrapConns is the most connections
+	// the bootstrap server serves at once.
+	MaxBootstrapConns = 16
+	// BootstrapIdleTimeout closes the connections which neither
+	// send a request nor read the response for this long.
+	BootstrapIdleTimeout = time.Minute
+)
+
+var errFrontiersDone = errors.New("sent the requested frontiers")
+
+// BootstrapLedger is the ledger data served to bootstrapping peers.
+type BootstrapLedger interface {
+	// Frontiers calls fn with the head block of every account
+	// from start on, in order, until fn returns an error.
+	Frontiers(start types.PubKey, fn func(types.PubKey, types.BlockHash) error) error
+	// Chain calls fn with the blocks of the account chain from
+	// its head back to end, which isn't included.
+	Chain(account types.PubKey, end types.BlockHash, fn func(blocks.Block) error) error
+}
+
+// BootstrapServer answers the frontier and bulk pull requests
+// of peers bootstrapping from the node over tcp, and accepts
+// the blocks peers push to it.
+type BootstrapServer struct {
+	ledger BootstrapLedger
+	// pushed is called with every block pushed by a peer,
+	// returning false if the node no longer takes blocks.
+	pushed func(blocks.Block) bool
+	addr   string
+	ln     net.Listener
+	// slots holds a token for every connection being served.
+	slots chan struct{}
+	mu    sync.Mutex
+	conns map[net.Conn]bool
+	wg    sync.WaitGroup
+}
+
+func NewBootstrapServer(l BootstrapLedger, pushed func(blocks.Block) bool, addr string) *BootstrapServer {
+	s := new(BootstrapServer)
+
+	s.ledger = l
+	s.pushed = pushed
+	s.addr = addr
+	s.slots = make(chan struct{}, MaxBootstrapConns)
+	s.conns = make(map[net.Conn]bool)
+
+	return s
+}
+
+func (s *BootstrapServer) Start() error {
+	ln, err := net.Listen("tcp", s.addr)
+	if err != nil {
+		return err
+	}
+	s.ln = ln
+
+	log.WithFields(log.Fields{"addr": ln.Addr().String()}).Info("Listening for bootstrap connections")
+
+	s.wg.Add(1)
+	go s.accept()
+
+	return nil
+}
+
+// Stop closes the listener along with the connections being
+// served, and waits for them to be done with.
+func (s *BootstrapServer) Stop() {
+	s.ln.Close()
+
+	s.mu.Lock()
+	for conn := range s.conns {
+		conn.Close()
+	}
+	s.mu.Unlock()
+
+	s.wg.Wait()
+}
+
+// Addr returns the address the server listens on.
+func (s *BootstrapServer) Addr() net.Addr {
+	return s.ln.Addr()
+}
+
+func (s *BootstrapServer) accept() {
+	defer s.wg.Done()
+
+	for {
+		conn, err := s.ln.Accept()
+		if err != nil {
+			// The listener has been closed
+			return
+		}
+
+		select {
+		case s.slots <- struct{}{}:
+		default:
+			log.WithFields(log.Fields{"peer": conn.RemoteAddr().String()}).Debug("Too many bootstrap connections, refused peer")
+			conn.Close()
+			continue
+		}
+
+		s.mu.Lock()
+		s.conns[conn] = true
+		s.mu.Unlock()
+
+		s.wg.Add(1)
+		go s.serve(conn)
+	}
+}
+
+// serve answers the requests made over conn,
+// until it fails or is idle for too long.
+func (s *BootstrapServer) serve(conn net.Conn) {
+	defer func() {
+		conn.Close()
+
+		s.mu.Lock()
+		delete(s.conns, conn)
+		s.mu.Unlock()
+
+		<-s.slots
+		s.wg.Done()
+	}()
+
+	peer := conn.RemoteAddr().String()
+	r := bufio.NewReader(conn)
+	w := bufio.NewWriter(&deadlineWriter{conn})
+	for {
+		conn.SetReadDeadline(time.Now().Add(BootstrapIdleTimeout))
+		hb := make([]byte, HeaderSize)
+		if _, err := io.ReadFull(r, hb); err != nil {
+			return
+		}
+
+		h := new(Header)
+		if err := h.Unmarshal(hb); err != nil || h.MagicNumber != MagicNumber {
+			log.WithFields(log.Fields{"peer": peer}).Debug("Received bootstrap request of another network")
+			return
+		}
+
+		var err error
+		switch h.Type {
+		case msgFrontierReq:
+			m := new(FrontierReq)
+			if err = readBody(r, m, 40); err == nil {
+				err = s.serveFrontiers(w, m)
+			}
+		case msgBulkPull:
+			m := new(BulkPull)
+			if err = readBody(r, m, 64); err == nil {
+				err = s.serveBulkPull(w, m)
+			}
+		case msgBulkPush:
+			err = s.receiveBulkPush(conn, r)
+		default:
+			err = errors.Errorf("unexpected message type %d", h.Type)
+		}
+
+		if err == nil {
+			err = w.Flush()
+		}
+
+		if err != nil {
+			log.WithFields(log.Fields{"peer": peer, "err": err.Error()}).Debug("Failed serving bootstrap request")
+			return
+		}
+	}
+}
+
+// serveFrontiers writes the requested number of account and head
+// pairs, ending with a zero pair. Accounts aren't filtered by age.
+func (s *BootstrapServer) serveFrontiers(w io.Writer, m *FrontierReq) error {
+	var sent uint32
+	err := s.ledger.Frontiers(types.PubKeyFromSlice(m.Start[:]), func(pub types.PubKey, head types.BlockHash) error {
+		if sent == m.Count {
+			return errFrontiersDone
+		}
+		sent++
+
+		if _, err := w.Write(pub); err != nil {
+			return err
+		}
+
+		_, err := w.Write(head[:])
+		return err
+	})
+	if err != nil && err != errFrontiersDone {
+		return err
+	}
+
+	_, err = w.Write(make([]byte, 64))
+	return err
+}
+
+// serveBulkPull writes the blocks of the requested chain, each
+// prefixed with its type, ending with the not a block type.
+// Unknown accounts get an empty chain.
+func (s *BootstrapServer) serveBulkPull(w io.Writer, m *BulkPull) error {
+	var werr error
+	err := s.ledger.Chain(types.PubKeyFromSlice(m.Start[:]), m.End, func(b blocks.Block) error {
+		mb := FromBlock(b)
+		data, err := mb.Marshal()
+		if err != nil {
+			return err
+		}
+
+		if _, werr = w.Write(append([]byte{mb.Type}, data...)); werr != nil {
+			return werr
+		}
+
+		return nil
+	})
+	if werr != nil {
+		return werr
+	}
+
+	if err != nil {
+		log.WithFields(log.Fields{"account": types.PubKeyFromSlice(m.Start[:]).Address(), "err": err.Error()}).Debug("Failed walking pulled chain")
+	}
+
+	_, err = w.Write([]byte{notABlock})
+	return err
+}
+
+// receiveBulkPush reads the pushed blocks up to the not a block
+// type, handing them over unless their work is invalid.
+// Fails once the blocks are no longer accepted.
+func (s *BootstrapServer) receiveBulkPush(conn net.Conn, r io.Reader) error {
+	for {
+		conn.SetReadDeadline(time.Now().Add(BootstrapIdleTimeout))
+		b, err := readBlock(r)
+		if err != nil {
+			return errors.Wrap(err, "failed reading pushed block")
+		}
+
+		if b == nil {
+			return nil
+		}
+
+		if !blocks.ValidateBlockWork(b) {
+			return errors.Errorf("pushed block %s has invalid work", b.Hash())
+		}
+
+		if !s.pushed(b) {
+			return errors.New("pushed block wasn't accepted")
+		}
+	}
+}
+
+func readBody(r io.Reader, m MessagePart, size int) error {
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return err
+	}
+
+	return m.Unmarshal(data)
+}
+
+// deadlineWriter fails writes to a peer which
+// doesn't read them within the idle timeout.
+type deadlineWriter struct {
+	conn net.Conn
+}
+
+func (w *deadlineWriter) Write(data []byte) (int, error) {
+	w.conn.SetWriteDeadline(time.Now().Add(BootstrapIdleTimeout))
+	return w.conn.Write(data)
+}
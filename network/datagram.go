@@ -109,6 +109,10 @@ func (m *Message) UnmarshalBody(data []byte) error {
 		m.Body = &ConfirmReq{Block: Block{Type: m.Header.BlockType}}
 	case msgConfirmAck:
 		m.Body = &ConfirmAck{Vote: Vote{Block: Block{Type: m.Header.BlockType}}}
+	case msgBulkPull:
+		m.Body = new(BulkPull)
+	case msgFrontierReq:
+		m.Body = new(FrontierReq)
 	default:
 		return errors.New("message type undefined")
 	}
@@ -1,29 +1,56 @@
 package network
 
 import (
+	"fmt"
+	"math"
 	"math/rand"
 	"net"
+	"sync"
 	"time"
 
+	"github.com/s1na/nano/blocks"
+
 	log "github.com/sirupsen/logrus"
 )
 
+// DefaultPort is the port of the live network.
+const DefaultPort = 7075
+
 const packetSize = 512
 const numberOfPeersToShare = 8
 
+// recentBlocksSize is the number of recently
+// published blocks remembered to skip duplicates.
+const recentBlocksSize = 4096
+
 type Network struct {
-	PeerList []Peer
-	PeerSet  map[string]bool
-	LocalIP  string
-	stop     chan bool
+	// mu guards the peers, which are added while
+	// listening and read when sending messages.
+	mu      sync.RWMutex
+	peers   []Peer
+	peerSet map[string]bool
+	LocalIP string
+	// Port is the one peers listen on for udp packets.
+	Port uint16
+	// Votes receives the validly signed votes from peers.
+	Votes chan *Vote
+	// Blocks receives the blocks published by peers, which
+	// have valid work and haven't been published recently.
+	Blocks chan blocks.Block
+	recent *recentBlocks
+	stop   chan bool
 }
 
 func NewNetwork() *Network {
 	n := new(Network)
 
-	n.PeerList = make([]Peer, 0, 5)
-	n.PeerSet = make(map[string]bool)
+	n.peers = make([]Peer, 0, 5)
+	n.peerSet = make(map[string]bool)
 	n.LocalIP = getOutboundIP().String()
+	n.Port = DefaultPort
+	n.Votes = make(chan *Vote, 64)
+	n.Blocks = make(chan blocks.Block, 256)
+	n.recent = newRecentBlocks(recentBlocksSize)
 	n.stop = make(chan bool, 1)
 
 	return n
@@ -39,8 +66,8 @@ func (n *Network) ListenForUdp() {
 }
 
 func (n *Network) listenForUdp() {
-	log.Info("Listening for udp packets on 7075")
-	ln, err := net.ListenPacket("udp", ":7075")
+	log.WithFields(log.Fields{"port": n.Port}).Info("Listening for udp packets")
+	ln, err := net.ListenPacket("udp", fmt.Sprintf(":%d", n.Port))
 	if err != nil {
 		panic(err)
 	}
@@ -70,16 +97,48 @@ func (n *Network) listenForUdp() {
 }
 
 func (n *Network) AddPeer(p Peer) {
-	if !n.PeerSet[p.String()] && p.IP.String() != n.LocalIP {
-		n.PeerSet[p.String()] = true
-		n.PeerList = append(n.PeerList, p)
+	if p.IP.String() == n.LocalIP {
+		return
+	}
+
+	n.mu.Lock()
+	defer n.mu.Unlock()
+
+	if !n.peerSet[p.String()] {
+		n.peerSet[p.String()] = true
+		n.peers = append(n.peers, p)
 		log.WithFields(log.Fields{
 			"peer": p.String(),
-			"len":  len(n.PeerList),
+			"len":  len(n.peers),
 		}).Info("Added new peer to list")
 	}
 }
 
+// Peers returns a copy of the list of known peers.
+func (n *Network) Peers() []Peer {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	return append([]Peer{}, n.peers...)
+}
+
+// randomPeers returns up to count peers picked at random.
+func (n *Network) randomPeers(count int) []Peer {
+	n.mu.RLock()
+	defer n.mu.RUnlock()
+
+	peers := make([]Peer, 0, count)
+	for j, i := range rand.Perm(len(n.peers)) {
+		if j == count {
+			break
+		}
+
+		peers = append(peers, n.peers[i])
+	}
+
+	return peers
+}
+
 func (n *Network) handleMessage(source string, data []byte) {
 	msg := new(Message)
 	if err := msg.Unmarshal(data); err != nil {
@@ -87,7 +146,7 @@ func (n *Network) handleMessage(source string, data []byte) {
 		return
 	}
 
-	sp := Peer{net.ParseIP(source), 7075}
+	sp := Peer{net.ParseIP(source), n.Port}
 	n.AddPeer(sp)
 
 	switch m := msg.Body.(type) {
@@ -96,45 +155,93 @@ func (n *Network) handleMessage(source string, data []byte) {
 			n.AddPeer(peer)
 		}
 	case *Publish:
-		//store.StoreBlock(m.ToBlock())
+		n.handlePublish(source, m)
 	case *ConfirmAck:
-		//store.StoreBlock(m.ToBlock())
+		if !m.Vote.VerifySignature() {
+			log.WithFields(log.Fields{"source": source}).Warn("Received vote with invalid signature")
+			return
+		}
+
+		select {
+		case n.Votes <- &m.Vote:
+		default:
+			log.WithFields(log.Fields{"source": source}).Debug("Dropped vote, too many pending")
+		}
 	}
 
 	return
 }
 
-func (n *Network) SendKeepAlive(peer Peer) error {
-	addr := peer.Addr()
-	randomPeers := make([]Peer, 0, 2)
+func (n *Network) handlePublish(source string, m *Publish) {
+	b := m.ToBlock()
+	if b == nil {
+		return
+	}
 
-	outConn, err := net.DialUDP("udp", nil, addr)
-	if err != nil {
-		return err
+	if !blocks.ValidateBlockWork(b) {
+		log.WithFields(log.Fields{"source": source, "block": b.Hash()}).Debug("Received block with invalid work")
+		return
 	}
 
-	randIndices := rand.Perm(len(n.PeerList))
-	for j, i := range randIndices {
-		if j == numberOfPeersToShare {
-			break
+	if n.recent.contains(b.Hash()) {
+		return
+	}
+
+	// Blocks are only remembered once queued, so
+	// that dropped ones are taken when seen again
+	select {
+	case n.Blocks <- b:
+		n.recent.add(b.Hash())
+	default:
+		log.WithFields(log.Fields{"source": source}).Debug("Dropped published block, too many pending")
+	}
+}
+
+// Flood publishes b to a random subset of the peers,
+// as many as the square root of their number.
+func (n *Network) Flood(b blocks.Block) {
+	n.recent.add(b.Hash())
+
+	m := &Publish{Block: *FromBlock(b)}
+	msg := NewMessage(msgPublish, m)
+	msg.Header.BlockType = m.Type
+
+	n.mu.RLock()
+	fanout := int(math.Ceil(math.Sqrt(float64(len(n.peers)))))
+	n.mu.RUnlock()
+
+	for _, peer := range n.randomPeers(fanout) {
+		if err := n.send(peer, msg); err != nil {
+			log.WithFields(log.Fields{"peer": peer.String(), "err": err.Error()}).Debug("Failed publishing block")
 		}
-		randomPeers = append(randomPeers, n.PeerList[i])
 	}
+}
 
-	m := NewKeepAlive(randomPeers)
-	msg := NewMessage(msgKeepalive, m)
+func (n *Network) send(peer Peer, msg *Message) error {
 	data, err := msg.Marshal()
 	if err != nil {
 		return err
 	}
 
-	outConn.Write(data)
+	conn, err := net.DialUDP("udp", nil, peer.Addr())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	_, err = conn.Write(data)
+	return err
+}
+
+func (n *Network) SendKeepAlive(peer Peer) error {
+	m := NewKeepAlive(n.randomPeers(numberOfPeersToShare))
+	msg := NewMessage(msgKeepalive, m)
 
-	return nil
+	return n.send(peer, msg)
 }
 
 func (n *Network) SendKeepAlives(params []interface{}) {
-	for _, peer := range n.PeerList {
+	for _, peer := range n.Peers() {
 		// TODO: Handle errors
 		n.SendKeepAlive(peer)
 	}
@@ -2,6 +2,9 @@ package network
 
 import (
 	"encoding/hex"
+	"errors"
+	"io"
+	"net"
 	"testing"
 
 	"github.com/frankh/crypto/ed25519"
@@ -121,6 +124,244 @@ func TestReadWritePublish(t *testing.T) {
 	assert.Equal(t, "xrb_14jyjetsh8p7jxx1of38ctsa779okt9d1pdnmtjpqiukuq8zugr3bxpxf1zu", block.Account.Address())
 }
 
+func TestReadWriteUtx(t *testing.T) {
+	var m Block
+	data := make([]byte, utxSize)
+	for i := range data {
+		data[i] = byte(i)
+	}
+
+	m.Type = utxBlock
+	err := m.Unmarshal(data)
+	require.Nil(t, err)
+
+	b := m.ToBlock().(*blocks.UtxBlock)
+	assert.Equal(t, data[:32], []byte(b.Account))
+	assert.Equal(t, data[112:144], []byte(b.Link))
+	assert.Equal(t, types.Reversed(data[208:216]), b.Work[:])
+
+	out, err := m.Marshal()
+	require.Nil(t, err)
+	assert.Equal(t, data, out)
+}
+
+func TestHandleMalformedVote(t *testing.T) {
+	n := &Network{Votes: make(chan *Vote, 1), peerSet: make(map[string]bool)}
+
+	// A confirm ack whose header has no valid block type
+	for _, bt := range []byte{invalidBlock, notABlock, 0xff} {
+		data := append([]byte{}, confirmAck...)
+		data[7] = bt
+		n.handleMessage("::1", data)
+		assert.Len(t, n.Votes, 0)
+	}
+
+	v := &Vote{Block: Block{Type: invalidBlock}}
+	assert.Nil(t, v.Hash())
+	assert.False(t, v.VerifySignature())
+}
+
+func TestFromBlock(t *testing.T) {
+	for _, data := range [][]byte{publishSend, publishReceive, publishOpen, publishChange} {
+		msg := new(Message)
+		require.Nil(t, msg.Unmarshal(data))
+
+		m := msg.Body.(*Publish)
+		assert.Equal(t, &m.Block, FromBlock(m.ToBlock()))
+	}
+}
+
+func TestHandlePublish(t *testing.T) {
+	n := &Network{Blocks: make(chan blocks.Block, 4), recent: newRecentBlocks(2)}
+
+	msg := new(Message)
+	require.Nil(t, msg.Unmarshal(publishSend))
+	send := msg.Body.(*Publish)
+
+	// Duplicates are skipped
+	n.handlePublish("::1", send)
+	n.handlePublish("::1", send)
+	require.Len(t, n.Blocks, 1)
+	assert.Equal(t, send.ToBlock().Hash(), (<-n.Blocks).Hash())
+
+	require.Nil(t, msg.Unmarshal(publishWrongWork))
+	n.handlePublish("::1", msg.Body.(*Publish))
+	assert.Len(t, n.Blocks, 0)
+
+	// Blocks dropped while the queue is full are taken when seen again
+	require.Nil(t, msg.Unmarshal(publishReceive))
+	receive := msg.Body.(*Publish)
+	n.Blocks = make(chan blocks.Block)
+	n.handlePublish("::1", receive)
+	n.Blocks = make(chan blocks.Block, 1)
+	n.handlePublish("::1", receive)
+	assert.Len(t, n.Blocks, 1)
+}
+
+func TestBootstrapClient(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	defer ln.Close()
+
+	msg := new(Message)
+	require.Nil(t, msg.Unmarshal(publishSend))
+	send := msg.Body.(*Publish).Block
+	require.Nil(t, msg.Unmarshal(publishReceive))
+	receive := msg.Body.(*Publish).Block
+
+	account := make([]byte, 32)
+	account[0] = 1
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		req := make([]byte, HeaderSize+40)
+		io.ReadFull(conn, req)
+		conn.Write(append(account, send.Previous[:]...))
+		conn.Write(make([]byte, 64))
+
+		req = make([]byte, HeaderSize+64)
+		io.ReadFull(conn, req)
+		for _, m := range []Block{receive, send} {
+			data, _ := m.Marshal()
+			conn.Write(append([]byte{m.Type}, data...))
+		}
+		conn.Write([]byte{notABlock})
+	}()
+
+	c, err := DialBootstrap(ln.Addr().String())
+	require.Nil(t, err)
+	defer c.Close()
+
+	var frontiers []types.BlockHash
+	err = c.Frontiers(nil, func(pub types.PubKey, head types.BlockHash) error {
+		assert.Equal(t, types.PubKey(account), pub)
+		frontiers = append(frontiers, head)
+		return nil
+	})
+	require.Nil(t, err)
+	assert.Equal(t, []types.BlockHash{send.Previous}, frontiers)
+
+	var pulled []types.BlockHash
+	err = c.BulkPull(account, types.BlockHash{}, func(b blocks.Block) error {
+		pulled = append(pulled, b.Hash())
+		return nil
+	})
+	require.Nil(t, err)
+	assert.Equal(t, []types.BlockHash{receive.ToBlock().Hash(), send.ToBlock().Hash()}, pulled)
+}
+
+// testBootstrapLedger serves one account, whose
+// chain is its blocks, newest first.
+type testBootstrapLedger struct {
+	account types.PubKey
+	blocks  []blocks.Block
+}
+
+func (l *testBootstrapLedger) Frontiers(start types.PubKey, fn func(types.PubKey, types.BlockHash) error) error {
+	return fn(l.account, l.blocks[0].Hash())
+}
+
+func (l *testBootstrapLedger) Chain(account types.PubKey, end types.BlockHash, fn func(blocks.Block) error) error {
+	if !account.Equal(l.account) {
+		return errors.New("account not found")
+	}
+
+	for _, b := range l.blocks {
+		if b.Hash() == end {
+			break
+		}
+
+		if err := fn(b); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func TestBootstrapServer(t *testing.T) {
+	msg := new(Message)
+	require.Nil(t, msg.Unmarshal(publishReceive))
+	receive := msg.Body.(*Publish).ToBlock()
+	require.Nil(t, msg.Unmarshal(publishSend))
+	send := msg.Body.(*Publish).ToBlock()
+
+	account := make([]byte, 32)
+	account[0] = 1
+	l := &testBootstrapLedger{account, []blocks.Block{receive, send}}
+
+	s := NewBootstrapServer(l, func(blocks.Block) bool { return true }, "127.0.0.1:0")
+	require.Nil(t, s.Start())
+	defer s.Stop()
+
+	c, err := DialBootstrap(s.Addr().String())
+	require.Nil(t, err)
+	defer c.Close()
+
+	var heads []types.BlockHash
+	err = c.Frontiers(nil, func(pub types.PubKey, head types.BlockHash) error {
+		assert.Equal(t, types.PubKey(account), pub)
+		heads = append(heads, head)
+		return nil
+	})
+	require.Nil(t, err)
+	assert.Equal(t, []types.BlockHash{receive.Hash()}, heads)
+
+	// Requests are served one after another on a connection
+	var pulled []blocks.Block
+	err = c.BulkPull(account, send.Hash(), func(b blocks.Block) error {
+		pulled = append(pulled, b)
+		return nil
+	})
+	require.Nil(t, err)
+	require.Len(t, pulled, 1)
+	assert.Equal(t, receive.Hash(), pulled[0].Hash())
+
+	// Unknown accounts have empty chains
+	pulled = nil
+	err = c.BulkPull(make([]byte, 32), types.BlockHash{}, func(b blocks.Block) error {
+		pulled = append(pulled, b)
+		return nil
+	})
+	require.Nil(t, err)
+	assert.Len(t, pulled, 0)
+}
+
+func TestBulkPush(t *testing.T) {
+	msg := new(Message)
+	require.Nil(t, msg.Unmarshal(publishSend))
+	send := msg.Body.(*Publish).ToBlock()
+	require.Nil(t, msg.Unmarshal(publishReceive))
+	receive := msg.Body.(*Publish).ToBlock()
+
+	account := make([]byte, 32)
+	l := &testBootstrapLedger{account, []blocks.Block{receive}}
+
+	var pushed []types.BlockHash
+	s := NewBootstrapServer(l, func(b blocks.Block) bool {
+		pushed = append(pushed, b.Hash())
+		return true
+	}, "127.0.0.1:0")
+	require.Nil(t, s.Start())
+	defer s.Stop()
+
+	c, err := DialBootstrap(s.Addr().String())
+	require.Nil(t, err)
+	defer c.Close()
+
+	require.Nil(t, c.BulkPush([]blocks.Block{send, receive}))
+
+	// Answered once the push has been read
+	err = c.Frontiers(nil, func(types.PubKey, types.BlockHash) error { return nil })
+	require.Nil(t, err)
+	assert.Equal(t, []types.BlockHash{send.Hash(), receive.Hash()}, pushed)
+}
+
 func validateTestBlock(t *testing.T, b blocks.Block, expectedHash types.BlockHash) {
 	assert.Equal(t, expectedHash, b.Hash())
 	assert.True(t, blocks.ValidateBlockWork(b))
@@ -0,0 +1,54 @@
+package network
+
+import (
+	"sync"
+
+	"github.com/s1na/nano/types"
+)
+
+// recentBlocks remembers the hashes of the last
+// blocks seen, forgetting the oldest ones first.
+type recentBlocks struct {
+	mu     sync.Mutex
+	size   int
+	hashes map[types.BlockHash]bool
+	order  []types.BlockHash
+}
+
+func newRecentBlocks(size int) *recentBlocks {
+	r := new(recentBlocks)
+
+	r.size = size
+	r.hashes = make(map[types.BlockHash]bool, size)
+	r.order = make([]types.BlockHash, 0, size)
+
+	return r
+}
+
+// add records hash, and reports whether it's new.
+func (r *recentBlocks) add(hash types.BlockHash) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.hashes[hash] {
+		return false
+	}
+
+	if len(r.order) == r.size {
+		delete(r.hashes, r.order[0])
+		r.order = r.order[1:]
+	}
+
+	r.hashes[hash] = true
+	r.order = append(r.order, hash)
+
+	return true
+}
+
+// contains reports whether hash was recorded.
+func (r *recentBlocks) contains(hash types.BlockHash) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.hashes[hash]
+}
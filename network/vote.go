@@ -3,6 +3,7 @@ package network
 import (
 	"errors"
 
+	"github.com/frankh/crypto/ed25519"
 	"github.com/golang/crypto/blake2b"
 )
 
@@ -13,15 +14,32 @@ type Vote struct {
 	Block
 }
 
+// Hash returns the hash the vote is signed over,
+// or nil if its block is of an unknown type.
 func (m *Vote) Hash() []byte {
+	b := m.Block.ToBlock()
+	if b == nil {
+		return nil
+	}
+
 	hash, _ := blake2b.New(32, nil)
 
-	hash.Write(m.Block.ToBlock().Hash().Slice())
+	hash.Write(b.Hash().Slice())
 	hash.Write(m.Sequence[:])
 
 	return hash.Sum(nil)
 }
 
+// VerifySignature reports whether the vote is signed by its account.
+func (m *Vote) VerifySignature() bool {
+	hash := m.Hash()
+	if hash == nil {
+		return false
+	}
+
+	return ed25519.Verify(ed25519.PublicKey(m.Account[:]), hash, m.Signature[:])
+}
+
 func (m *Vote) Unmarshal(data []byte) error {
 	vb, bb := data[:104], data[104:]
 	if len(vb) != 104 || len(bb) == 0 {
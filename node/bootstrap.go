@@ -0,0 +1,180 @@
+package node
+
+import (
+	"math/rand"
+	"sync/atomic"
+
+	"github.com/s1na/nano/account"
+	"github.com/s1na/nano/blocks"
+	"github.com/s1na/nano/network"
+	"github.com/s1na/nano/store"
+	"github.com/s1na/nano/types"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// segment is the part of the chain of an account missing on one
+// side, from the head of the other side back to end, which isn't
+// included. A zero end stands for the whole chain.
+type segment struct {
+	account types.PubKey
+	end     types.BlockHash
+}
+
+// Bootstrap syncs the ledger with the bootstrap server at addr. Its
+// frontiers are compared with the heads of the local accounts. The
+// chain segments missing locally are pulled and queued on the block
+// processor, and the ones the peer is missing are pushed to it.
+func (n *Node) Bootstrap(addr string) error {
+	c, err := network.DialBootstrap(addr)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	pulls, pushes, err := n.compareFrontiers(c)
+	if err != nil {
+		return err
+	}
+
+	total := 0
+	for _, p := range pulls {
+		var chain []blocks.Block
+		err := c.BulkPull(p.account, p.end, func(b blocks.Block) error {
+			chain = append(chain, b)
+			return nil
+		})
+		if err != nil {
+			return errors.Wrapf(err, "failed pulling account %s", p.account.Address())
+		}
+
+		// Blocks are pulled newest first, and
+		// queued in the order they were made
+		for i := len(chain) - 1; i >= 0; i-- {
+			if !n.processor.AddPulled(chain[i]) {
+				return ErrProcessorStopped
+			}
+		}
+
+		total += len(chain)
+	}
+
+	pushed, err := n.push(c, pushes)
+	if err != nil {
+		return err
+	}
+
+	log.WithFields(log.Fields{"peer": addr, "pulled": total, "pushed": pushed}).Info("Bootstrapped from peer")
+
+	return nil
+}
+
+// push uploads the chain segments the peer is missing, each
+// oldest block first, returning the number of pushed blocks.
+func (n *Node) push(c *network.BootstrapClient, pushes []segment) (int, error) {
+	if len(pushes) == 0 {
+		return 0, nil
+	}
+
+	var bs []blocks.Block
+	for _, p := range pushes {
+		var chain []blocks.Block
+		err := n.ledger.Chain(p.account, p.end, func(b blocks.Block) error {
+			chain = append(chain, b)
+			return nil
+		})
+		if err != nil {
+			return 0, errors.Wrapf(err, "failed walking the chain of account %s", p.account.Address())
+		}
+
+		for i := len(chain) - 1; i >= 0; i-- {
+			bs = append(bs, chain[i])
+		}
+	}
+
+	if err := c.BulkPush(bs); err != nil {
+		return 0, errors.Wrap(err, "failed pushing blocks")
+	}
+
+	return len(bs), nil
+}
+
+// compareFrontiers requests the frontiers of the peer, returning the
+// chain segments to pull for the accounts whose head isn't known
+// locally, and to push for the ones the peer is behind on.
+func (n *Node) compareFrontiers(c *network.BootstrapClient) ([]segment, []segment, error) {
+	as := account.NewAccountStore(n.store)
+
+	var pulls, pushes []segment
+	remote := make(map[string]bool)
+	err := c.Frontiers(nil, func(pub types.PubKey, head types.BlockHash) error {
+		remote[string(pub)] = true
+
+		acc, err := as.GetAccount(pub)
+		if err != nil {
+			if err == store.ErrKeyNotFound {
+				pulls = append(pulls, segment{pub, types.BlockHash{}})
+				return nil
+			}
+
+			return err
+		}
+
+		if acc.Head == head {
+			return nil
+		}
+
+		known, err := n.ledger.HasBlock(head)
+		if err != nil {
+			return err
+		}
+
+		if known {
+			pushes = append(pushes, segment{pub, head})
+		} else {
+			pulls = append(pulls, segment{pub, acc.Head})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, errors.Wrap(err, "failed requesting frontiers")
+	}
+
+	// Accounts the peer doesn't have at all
+	err = n.ledger.Frontiers(nil, func(pub types.PubKey, head types.BlockHash) error {
+		if !remote[string(pub)] {
+			pushes = append(pushes, segment{pub, types.BlockHash{}})
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pulls, pushes, nil
+}
+
+// bootstrapPeer bootstraps from a random peer in the background,
+// unless bootstrapping is already underway.
+func (n *Node) bootstrapPeer(params []interface{}) {
+	peers := n.Net.Peers()
+	if len(peers) == 0 {
+		return
+	}
+
+	if !atomic.CompareAndSwapInt32(&n.bootstrapping, 0, 1) {
+		return
+	}
+
+	peer := peers[rand.Intn(len(peers))]
+	go func() {
+		defer atomic.StoreInt32(&n.bootstrapping, 0)
+
+		if err := n.Bootstrap(peer.String()); err != nil {
+			log.WithFields(log.Fields{"peer": peer.String(), "err": err.Error()}).Warn("Failed bootstrapping")
+		}
+	}()
+}
@@ -1,16 +1,18 @@
 package node
 
 import (
+	"encoding/binary"
+	"fmt"
 	"os"
 	"os/signal"
 	"time"
 
-	"github.com/s1na/nano/blocks"
 	"github.com/s1na/nano/config"
 	"github.com/s1na/nano/ledger"
 	"github.com/s1na/nano/network"
 	"github.com/s1na/nano/rpc"
 	"github.com/s1na/nano/store"
+	"github.com/s1na/nano/types"
 	"github.com/s1na/nano/wallet"
 
 	log "github.com/sirupsen/logrus"
@@ -24,25 +26,38 @@ type Node struct {
 	ledger    *ledger.Ledger
 	wallets   map[string]*wallet.Wallet
 	walletsCh chan *wallet.Wallet
-	blocksCh  chan blocks.Block
+	processor *BlockProcessor
+	// bootstrapServer serves peers bootstrapping from the node.
+	bootstrapServer *network.BootstrapServer
+	// bootstrapping is set while bootstrapping from a peer.
+	bootstrapping int32
 }
 
 func NewNode(conf *config.Config) *Node {
 	n := new(Node)
 
 	config.Conf = conf
-	blocks.GenesisBlock = blocks.LiveGenesisBlock
-	if conf.TestNet {
-		blocks.GenesisBlock = blocks.TestGenesisBlock
+	if conf.Network == nil {
+		conf.Network = config.Live
 	}
+	conf.Network.Apply()
 
 	n.Net = network.NewNetwork()
-	n.store = store.NewStore(conf.DataDir)
+	n.Net.Port = conf.Network.PeeringPort
+	switch conf.Backend {
+	case config.MemoryBackend:
+		n.store = store.NewMemoryStore()
+	case "", config.BadgerBackend:
+		n.store = store.NewStore(conf.DataDir)
+	default:
+		log.WithFields(log.Fields{"backend": conf.Backend}).Fatal("Unknown storage backend")
+	}
 	n.ledger = ledger.NewLedger(n.store)
-	n.alarms = make([]*Alarm, 1)
+	n.alarms = make([]*Alarm, 4)
 	n.wallets = make(map[string]*wallet.Wallet)
 	n.walletsCh = make(chan *wallet.Wallet)
-	n.blocksCh = make(chan blocks.Block)
+	n.processor = NewBlockProcessor(n.ledger, n.Net.Flood)
+	n.bootstrapServer = network.NewBootstrapServer(n.ledger, n.processor.AddPulled, fmt.Sprintf(":%d", n.Net.Port))
 
 	return n
 }
@@ -61,9 +76,21 @@ func (n *Node) Start() {
 		log.Fatal(err)
 	}
 
+	n.processor.Start()
+
 	n.alarms[0] = NewAlarm(AlarmFn(n.Net.SendKeepAlives), []interface{}{}, 20*time.Second)
+	n.alarms[1] = NewAlarm(AlarmFn(n.pruneUnchecked), []interface{}{}, time.Minute)
+	n.alarms[2] = NewAlarm(AlarmFn(n.processor.report), []interface{}{}, 10*time.Second)
+	n.alarms[3] = NewAlarm(AlarmFn(n.bootstrapPeer), []interface{}{}, 5*time.Minute)
+	if config.Conf.Pruning {
+		log.Info("Pruning confirmed blocks")
+		n.alarms = append(n.alarms, NewAlarm(AlarmFn(n.pruneLedger), []interface{}{}, time.Minute))
+	}
 	n.Net.ListenForUdp()
-	n.rpc = rpc.NewServer(n.store, n.walletsCh, n.blocksCh)
+	if err := n.bootstrapServer.Start(); err != nil {
+		log.Fatal(err)
+	}
+	n.rpc = rpc.NewServer(fmt.Sprintf(":%d", config.Conf.Network.RPCPort), n.store, n.walletsCh, n.processor.Add)
 	n.rpc.Start()
 
 	n.loop()
@@ -71,7 +98,11 @@ func (n *Node) Start() {
 
 func (n *Node) Stop() {
 	n.rpc.Stop()
-	n.alarms[0].Stop()
+	for _, a := range n.alarms {
+		a.Stop()
+	}
+	n.bootstrapServer.Stop()
+	n.processor.Stop()
 	n.Net.Stop()
 }
 
@@ -90,10 +121,12 @@ func (n *Node) loop() {
 		case w := <-n.walletsCh:
 			log.WithFields(log.Fields{"wallet": w.Id}).Info("Adding wallet to node")
 			n.wallets[w.Id.Hex()] = w
-		case b := <-n.blocksCh:
-			err := n.ledger.AddBlock(b)
-			if err != nil {
-				log.WithFields(log.Fields{"block": b.Hash(), "err": err.Error()}).Warn("Failed adding block to ledger")
+		case v := <-n.Net.Votes:
+			// Votes may confirm blocks, which writes to the ledger
+			n.processor.Do(func() { n.processVote(v) })
+		case b := <-n.Net.Blocks:
+			if !n.processor.TryAdd(b) {
+				log.WithFields(log.Fields{"block": b.Hash()}).Debug("Block processor queue is full, dropped published block")
 			}
 		}
 	}
@@ -101,6 +134,56 @@ func (n *Node) loop() {
 	log.Info("Stopping node loop")
 }
 
+func (n *Node) processVote(v *network.Vote) {
+	rep := types.PubKeyFromSlice(v.Account[:])
+	b := v.Block.ToBlock()
+	if b == nil {
+		return
+	}
+
+	confirmed, err := n.ledger.ProcessVote(rep, binary.LittleEndian.Uint64(v.Sequence[:]), b)
+	if err != nil {
+		log.WithFields(log.Fields{"block": b.Hash(), "rep": rep.Address(), "err": err.Error()}).Warn("Failed processing vote")
+		return
+	}
+
+	if confirmed {
+		log.WithFields(log.Fields{"block": b.Hash()}).Info("Block confirmed by votes")
+	}
+}
+
+func (n *Node) pruneUnchecked(params []interface{}) {
+	n.processor.Do(n.doPruneUnchecked)
+}
+
+func (n *Node) doPruneUnchecked() {
+	pruned, err := n.ledger.PruneUnchecked()
+	if err != nil {
+		log.WithFields(log.Fields{"err": err.Error()}).Warn("Failed pruning unchecked blocks")
+		return
+	}
+
+	if pruned > 0 {
+		log.WithFields(log.Fields{"count": pruned}).Info("Pruned expired unchecked blocks")
+	}
+}
+
+func (n *Node) pruneLedger(params []interface{}) {
+	n.processor.Do(n.doPruneLedger)
+}
+
+func (n *Node) doPruneLedger() {
+	pruned, err := n.ledger.Prune()
+	if err != nil {
+		log.WithFields(log.Fields{"err": err.Error()}).Warn("Failed pruning ledger")
+		return
+	}
+
+	if pruned > 0 {
+		log.WithFields(log.Fields{"count": pruned}).Info("Pruned confirmed blocks")
+	}
+}
+
 func (n *Node) syncFromStore() error {
 	ws := wallet.NewWalletStore(n.store)
 	wallets, err := ws.GetWallets()
@@ -0,0 +1,253 @@
+package node
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/s1na/nano/blocks"
+	"github.com/s1na/nano/ledger"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// ProcessorQueueSize is the number of blocks which
+	// can wait for the block processor.
+	ProcessorQueueSize = 16384
+	// ProcessorBatchSize is the most blocks applied
+	// to the ledger within one store transaction.
+	ProcessorBatchSize = 256
+	// ProcessorJobQueueSize is the number of other ledger
+	// writes which can wait for the block processor.
+	ProcessorJobQueueSize = 1024
+)
+
+var ErrProcessorStopped = errors.New("block processor stopped")
+
+// BlockProcessor is the single writer applying blocks to the ledger.
+// Blocks wait in a bounded queue, which is drained in batches
+// that are each applied within one store transaction. Other ledger
+// writes, such as confirming or pruning, are run through Do so that
+// they don't conflict with the batches.
+type BlockProcessor struct {
+	ledger *ledger.Ledger
+	queue  chan queuedBlock
+	jobs   chan func()
+	// publish is called with the blocks added to the
+	// ledger which were queued to be published.
+	publish func(blocks.Block)
+	// quit is closed to stop the processor, which
+	// closes stopped once it's done with its batch.
+	quit    chan struct{}
+	stopped chan struct{}
+	// Counters, updated atomically.
+	processed uint64
+	added     uint64
+	dropped   uint64
+	batches   uint64
+	// Processed count and time of the last report,
+	// to derive the throughput in between.
+	lastProcessed uint64
+	lastReport    time.Time
+}
+
+// queuedBlock is a block waiting for the processor, along
+// with whether to publish it once it's added.
+type queuedBlock struct {
+	block   blocks.Block
+	publish bool
+}
+
+// ProcessorStats is a snapshot of the block processor metrics.
+type ProcessorStats struct {
+	Queued    int
+	Processed uint64
+	Added     uint64
+	Dropped   uint64
+	Batches   uint64
+}
+
+func NewBlockProcessor(l *ledger.Ledger, publish func(blocks.Block)) *BlockProcessor {
+	p := new(BlockProcessor)
+
+	p.ledger = l
+	p.queue = make(chan queuedBlock, ProcessorQueueSize)
+	p.jobs = make(chan func(), ProcessorJobQueueSize)
+	p.publish = publish
+	p.quit = make(chan struct{})
+	p.stopped = make(chan struct{})
+	p.lastReport = time.Now()
+
+	return p
+}
+
+func (p *BlockProcessor) Start() {
+	go p.run()
+}
+
+// Stop waits for the batch being applied, and stops the processor.
+// The blocks added from then on are dropped.
+func (p *BlockProcessor) Stop() {
+	close(p.quit)
+	<-p.stopped
+}
+
+// Add queues b to be published once added, waiting for room
+// while the queue is full. Returns false if the processor
+// is stopped, in which case b is dropped.
+func (p *BlockProcessor) Add(b blocks.Block) bool {
+	return p.wait(queuedBlock{b, true})
+}
+
+// AddPulled queues b, pulled from or pushed by a peer while
+// bootstrapping, which isn't published. Waits for room while
+// the queue is full, unless the processor is stopped.
+func (p *BlockProcessor) AddPulled(b blocks.Block) bool {
+	return p.wait(queuedBlock{b, false})
+}
+
+func (p *BlockProcessor) wait(q queuedBlock) bool {
+	select {
+	case p.queue <- q:
+		return true
+	case <-p.quit:
+		atomic.AddUint64(&p.dropped, 1)
+		return false
+	}
+}
+
+// TryAdd queues b to be published once added, unless
+// the queue is full, in which case b is dropped.
+func (p *BlockProcessor) TryAdd(b blocks.Block) bool {
+	select {
+	case p.queue <- queuedBlock{b, true}:
+		return true
+	default:
+		atomic.AddUint64(&p.dropped, 1)
+		return false
+	}
+}
+
+// Do queues fn to be run by the processor in between batches,
+// waiting for room while the queue is full. Returns false if
+// the processor is stopped, in which case fn isn't run.
+func (p *BlockProcessor) Do(fn func()) bool {
+	select {
+	case p.jobs <- fn:
+		return true
+	case <-p.quit:
+		return false
+	}
+}
+
+func (p *BlockProcessor) Stats() ProcessorStats {
+	return ProcessorStats{
+		Queued:    len(p.queue),
+		Processed: atomic.LoadUint64(&p.processed),
+		Added:     atomic.LoadUint64(&p.added),
+		Dropped:   atomic.LoadUint64(&p.dropped),
+		Batches:   atomic.LoadUint64(&p.batches),
+	}
+}
+
+func (p *BlockProcessor) run() {
+	defer close(p.stopped)
+
+	batch := make([]queuedBlock, 0, ProcessorBatchSize)
+	for {
+		select {
+		case <-p.quit:
+			return
+		case fn := <-p.jobs:
+			fn()
+			continue
+		case q := <-p.queue:
+			batch = append(batch[:0], q)
+		}
+
+		// Take whatever else is queued, up to a full batch
+	drain:
+		for len(batch) < ProcessorBatchSize {
+			select {
+			case q := <-p.queue:
+				batch = append(batch, q)
+			default:
+				break drain
+			}
+		}
+
+		p.process(batch)
+	}
+}
+
+func (p *BlockProcessor) process(batch []queuedBlock) {
+	fresh := make([]blocks.Block, 0, len(batch))
+	publish := make([]bool, 0, len(batch))
+	for _, q := range batch {
+		exists, err := p.ledger.HasBlock(q.block.Hash())
+		if err != nil {
+			log.WithFields(log.Fields{"block": q.block.Hash(), "err": err.Error()}).Warn("Failed looking up block")
+			continue
+		}
+
+		if !exists {
+			fresh = append(fresh, q.block)
+			publish = append(publish, q.publish)
+		}
+	}
+
+	results, err := p.ledger.AddBlocks(fresh)
+	if err != nil {
+		// Fall back to a transaction per block, so that
+		// one failing block doesn't hold back the others
+		log.WithFields(log.Fields{"count": len(fresh), "err": err.Error()}).Warn("Failed applying block batch, applying blocks one by one")
+		results = make([]error, len(fresh))
+		for i, b := range fresh {
+			results[i] = p.ledger.AddBlock(b)
+		}
+	}
+
+	added := 0
+	for i, b := range fresh {
+		switch err := results[i]; {
+		case err == nil:
+			added++
+			if publish[i] {
+				p.publish(b)
+			}
+		case err == ledger.ErrGapPrevious || err == ledger.ErrGapSource:
+			log.WithFields(log.Fields{"block": b.Hash(), "err": err.Error()}).Debug("Queued block with missing dependency")
+		case ledger.Rejected(err):
+			log.WithFields(log.Fields{"block": b.Hash(), "err": err.Error()}).Debug("Rejected block")
+		default:
+			log.WithFields(log.Fields{"block": b.Hash(), "err": err.Error()}).Warn("Failed adding block to ledger")
+		}
+	}
+
+	atomic.AddUint64(&p.processed, uint64(len(batch)))
+	atomic.AddUint64(&p.added, uint64(added))
+	atomic.AddUint64(&p.batches, 1)
+}
+
+// report logs the queue depth, and the throughput
+// since the last report if there's been any.
+func (p *BlockProcessor) report(params []interface{}) {
+	stats := p.Stats()
+	now := time.Now()
+	rate := float64(stats.Processed-p.lastProcessed) / now.Sub(p.lastReport).Seconds()
+	idle := stats.Processed == p.lastProcessed && stats.Queued == 0
+	p.lastProcessed, p.lastReport = stats.Processed, now
+	if idle {
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"queued":    stats.Queued,
+		"processed": stats.Processed,
+		"added":     stats.Added,
+		"dropped":   stats.Dropped,
+		"batches":   stats.Batches,
+		"rate":      rate,
+	}).Info("Block processor")
+}
@@ -4,11 +4,13 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"io/ioutil"
+	"math/big"
 	"net/http"
 	"strconv"
 
 	"github.com/s1na/nano/account"
 	"github.com/s1na/nano/blocks"
+	"github.com/s1na/nano/ledger"
 	"github.com/s1na/nano/types"
 	"github.com/s1na/nano/types/uint128"
 	"github.com/s1na/nano/wallet"
@@ -31,10 +33,13 @@ func NewHandler() *Handler {
 
 func (h *Handler) registerHandlers() {
 	h.fns = map[string]handlerFn{
-		"account_get":   handlerFn(accountGet),
-		"wallet_create": handlerFn(walletCreate),
-		"wallet_add":    handlerFn(walletAdd),
-		"send":          handlerFn(send),
+		"account_get":     handlerFn(accountGet),
+		"wallet_create":   handlerFn(walletCreate),
+		"wallet_add":      handlerFn(walletAdd),
+		"send":            handlerFn(send),
+		"forks":           handlerFn(forks),
+		"representatives": handlerFn(representatives),
+		"account_history": handlerFn(accountHistory),
 	}
 }
 
@@ -190,10 +195,102 @@ func send(w http.ResponseWriter, body *gjson.Result) error {
 	b.Work = types.GenerateWorkForHash(acc.Head)
 	b.Signature = wal.Accounts[source.Address()].Sign(b.Hash().Slice())
 
-	blocksCh <- b
+	if !addBlock(b) {
+		return errors.New("node is shutting down")
+	}
 
 	res["sent"] = "true"
 	json.NewEncoder(w).Encode(res)
 
 	return nil
 }
+
+func forks(w http.ResponseWriter, body *gjson.Result) error {
+	res := make(map[string][]string)
+
+	bs := blocks.NewBlockStore(db)
+	fs, err := bs.GetForks()
+	if err != nil {
+		return errors.New("internal error")
+	}
+
+	for root, competitors := range fs {
+		hashes := make([]string, 0, len(competitors))
+		for _, b := range competitors {
+			hashes = append(hashes, b.Hash().String())
+		}
+
+		res[root.String()] = hashes
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"forks": res})
+
+	return nil
+}
+
+func representatives(w http.ResponseWriter, body *gjson.Result) error {
+	res := make(map[string]string)
+
+	ws := ledger.NewWeightStore(db)
+	weights, err := ws.GetWeights()
+	if err != nil {
+		return errors.New("internal error")
+	}
+
+	for rep, weight := range weights {
+		res[rep] = rawAmount(weight)
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"representatives": res})
+
+	return nil
+}
+
+// rawAmount formats an amount of raw in decimal.
+func rawAmount(a uint128.Uint128) string {
+	return new(big.Int).SetBytes(a.GetBytes()).String()
+}
+
+func accountHistory(w http.ResponseWriter, body *gjson.Result) error {
+	pub, err := types.PubKeyFromAddress(body.Get("account").String())
+	if err != nil {
+		return err
+	}
+
+	var head types.BlockHash
+	if v := body.Get("head"); v.Exists() {
+		if head, err = types.BlockHashFromString(v.String()); err != nil {
+			return errors.New("invalid head")
+		}
+	}
+
+	filter := &ledger.HistoryFilter{Reverse: body.Get("reverse").Bool()}
+	for _, t := range body.Get("type").Array() {
+		filter.Types = append(filter.Types, blocks.BlockType(t.String()))
+	}
+
+	l := ledger.NewLedger(db)
+	entries, err := l.AccountHistory(pub, head, int(body.Get("count").Int()), int(body.Get("offset").Int()), filter)
+	if err != nil {
+		return err
+	}
+
+	res := make([]map[string]string, 0, len(entries))
+	for _, e := range entries {
+		entry := map[string]string{
+			"type":   string(e.Type),
+			"amount": rawAmount(e.Amount),
+			"hash":   e.Hash.String(),
+			"height": strconv.FormatUint(e.Height, 10),
+		}
+		if len(e.Account) != 0 {
+			entry["account"] = e.Account.Address()
+		}
+
+		res = append(res, entry)
+	}
+
+	json.NewEncoder(w).Encode(map[string]interface{}{"account": pub.Address(), "history": res})
+
+	return nil
+}
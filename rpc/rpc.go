@@ -16,7 +16,10 @@ import (
 var (
 	db        *store.Store
 	walletsCh chan *wallet.Wallet
-	blocksCh  chan blocks.Block
+	// addBlock queues a block on the block processor, waiting
+	// for room while its queue is full. Returns false if the
+	// processor is stopped.
+	addBlock func(blocks.Block) bool
 )
 
 type Server struct {
@@ -24,17 +27,17 @@ type Server struct {
 	handler *Handler
 }
 
-func NewServer(st *store.Store, wCh chan *wallet.Wallet, bCh chan blocks.Block) *Server {
+func NewServer(addr string, st *store.Store, wCh chan *wallet.Wallet, addFn func(blocks.Block) bool) *Server {
 	s := new(Server)
 
 	s.handler = NewHandler()
 	s.s = &http.Server{
-		Addr:    ":7076",
+		Addr:    addr,
 		Handler: s.handler,
 	}
 	db = st
 	walletsCh = wCh
-	blocksCh = bCh
+	addBlock = addFn
 
 	return s
 }
@@ -0,0 +1,122 @@
+package store
+
+import (
+	"github.com/dgraph-io/badger"
+)
+
+type badgerBackend struct {
+	db      *badger.DB
+	dataDir string
+}
+
+func NewBadgerBackend(dataDir string) Backend {
+	b := new(badgerBackend)
+
+	b.dataDir = dataDir
+
+	return b
+}
+
+func (b *badgerBackend) Open() error {
+	opts := badger.DefaultOptions
+	opts.Dir = b.dataDir
+	opts.ValueDir = b.dataDir
+	db, err := badger.Open(opts)
+	if err != nil {
+		return err
+	}
+
+	b.db = db
+
+	return nil
+}
+
+func (b *badgerBackend) Close() error {
+	return b.db.Close()
+}
+
+func (b *badgerBackend) Update(fn func(Txn) error) error {
+	return b.db.Update(func(txn *badger.Txn) error {
+		return fn(&badgerTxn{txn})
+	})
+}
+
+func (b *badgerBackend) View(fn func(Txn) error) error {
+	return b.db.View(func(txn *badger.Txn) error {
+		return fn(&badgerTxn{txn})
+	})
+}
+
+type badgerTxn struct {
+	txn *badger.Txn
+}
+
+func (t *badgerTxn) Set(k []byte, v []byte) error {
+	return t.txn.Set(k, v)
+}
+
+func (t *badgerTxn) Delete(k []byte) error {
+	return t.txn.Delete(k)
+}
+
+func (t *badgerTxn) Get(k []byte) ([]byte, error) {
+	item, err := t.txn.Get(k)
+	if err != nil {
+		if err == badger.ErrKeyNotFound {
+			return nil, ErrKeyNotFound
+		}
+
+		return nil, err
+	}
+
+	return item.ValueCopy(nil)
+}
+
+func (t *badgerTxn) GetPrefixKeys(prefix []byte) [][]byte {
+	keys := make([][]byte, 0, 2)
+
+	opts := badger.DefaultIteratorOptions
+	opts.PrefetchValues = false
+	it := t.txn.NewIterator(opts)
+	defer it.Close()
+
+	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
+		keys = append(keys, append([]byte{}, it.Item().Key()...))
+	}
+
+	return keys
+}
+
+func (t *badgerTxn) GetPrefixValues(prefix []byte) (map[string][]byte, error) {
+	res := make(map[string][]byte)
+	err := t.Iterate(prefix, func(k, v []byte) error {
+		res[string(k)] = v
+		return nil
+	})
+
+	return res, err
+}
+
+func (t *badgerTxn) Iterate(prefix []byte, fn func(k, v []byte) error) error {
+	return t.IterateFrom(prefix, nil, fn)
+}
+
+func (t *badgerTxn) IterateFrom(prefix, start []byte, fn func(k, v []byte) error) error {
+	it := t.txn.NewIterator(badger.DefaultIteratorOptions)
+	defer it.Close()
+
+	seek := append(append([]byte{}, prefix...), start...)
+	for it.Seek(seek); it.ValidForPrefix(prefix); it.Next() {
+		item := it.Item()
+		v, err := item.ValueCopy(nil)
+		if err != nil {
+			return err
+		}
+
+		if err := fn(append([]byte{}, item.Key()...), v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,167 @@
+package store
+
+import (
+	"bytes"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+var errReadOnly = errors.New("write in read-only transaction")
+
+// memoryBackend keeps all data in a map, which is lost when
+// the store stops. Transactions are serialized, and their
+// writes are applied to the map only on commit.
+type memoryBackend struct {
+	mu   sync.RWMutex
+	data map[string][]byte
+}
+
+func NewMemoryBackend() Backend {
+	b := new(memoryBackend)
+
+	b.data = make(map[string][]byte)
+
+	return b
+}
+
+func (b *memoryBackend) Open() error {
+	return nil
+}
+
+func (b *memoryBackend) Close() error {
+	return nil
+}
+
+func (b *memoryBackend) Update(fn func(Txn) error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	txn := &memoryTxn{b: b, writes: make(map[string][]byte), update: true}
+	if err := fn(txn); err != nil {
+		return err
+	}
+
+	for k, v := range txn.writes {
+		if v == nil {
+			delete(b.data, k)
+		} else {
+			b.data[k] = v
+		}
+	}
+
+	return nil
+}
+
+func (b *memoryBackend) View(fn func(Txn) error) error {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	return fn(&memoryTxn{b: b})
+}
+
+type memoryTxn struct {
+	b *memoryBackend
+	// writes holds the values set in this transaction,
+	// with nil marking deleted keys.
+	writes map[string][]byte
+	update bool
+}
+
+func (t *memoryTxn) Set(k []byte, v []byte) error {
+	if !t.update {
+		return errReadOnly
+	}
+
+	t.writes[string(k)] = append([]byte{}, v...)
+
+	return nil
+}
+
+func (t *memoryTxn) Delete(k []byte) error {
+	if !t.update {
+		return errReadOnly
+	}
+
+	t.writes[string(k)] = nil
+
+	return nil
+}
+
+func (t *memoryTxn) Get(k []byte) ([]byte, error) {
+	v, ok := t.writes[string(k)]
+	if !ok {
+		v, ok = t.b.data[string(k)]
+	}
+
+	if !ok || v == nil {
+		return nil, ErrKeyNotFound
+	}
+
+	return append([]byte{}, v...), nil
+}
+
+func (t *memoryTxn) GetPrefixKeys(prefix []byte) [][]byte {
+	keys := make([][]byte, 0, 2)
+	for _, k := range t.keys(prefix) {
+		keys = append(keys, []byte(k))
+	}
+
+	return keys
+}
+
+func (t *memoryTxn) GetPrefixValues(prefix []byte) (map[string][]byte, error) {
+	res := make(map[string][]byte)
+	err := t.Iterate(prefix, func(k, v []byte) error {
+		res[string(k)] = v
+		return nil
+	})
+
+	return res, err
+}
+
+func (t *memoryTxn) Iterate(prefix []byte, fn func(k, v []byte) error) error {
+	return t.IterateFrom(prefix, nil, fn)
+}
+
+func (t *memoryTxn) IterateFrom(prefix, start []byte, fn func(k, v []byte) error) error {
+	seek := string(prefix) + string(start)
+	for _, k := range t.keys(prefix) {
+		if k < seek {
+			continue
+		}
+
+		v, err := t.Get([]byte(k))
+		if err != nil {
+			return err
+		}
+
+		if err := fn([]byte(k), v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// keys returns the sorted keys with the given prefix, as
+// seen from within the transaction.
+func (t *memoryTxn) keys(prefix []byte) []string {
+	keys := make([]string, 0, 2)
+	for k := range t.b.data {
+		if _, ok := t.writes[k]; !ok && bytes.HasPrefix([]byte(k), prefix) {
+			keys = append(keys, k)
+		}
+	}
+
+	for k, v := range t.writes {
+		if v != nil && bytes.HasPrefix([]byte(k), prefix) {
+			keys = append(keys, k)
+		}
+	}
+
+	sort.Strings(keys)
+
+	return keys
+}
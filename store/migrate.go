@@ -0,0 +1,264 @@
+package store
+
+import (
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+	log "github.com/sirupsen/logrus"
+)
+
+// SchemaVersion is the version of the layout of the
+// stored data which this node reads and writes.
+const SchemaVersion uint32 = 5
+
+// MigrationBatchSize bounds the keys migrated within one transaction,
+// and the keys written by them, which keeps it within the size badger
+// allows. A batch ends after the migrated key reaching the bound.
+const MigrationBatchSize = 1000
+
+var (
+	versionKey  = []byte("schema_version")
+	progressKey = []byte("migration_progress")
+)
+
+var errBatchFull = errors.New("migration batch full")
+
+var ErrNewerSchema = errors.New("database schema is newer than supported")
+
+// Migration upgrades the stored data to Version from the version
+// just before it, calling Migrate for every key with Prefix.
+type Migration struct {
+	Version uint32
+	Name    string
+	Prefix  []byte
+	Migrate func(txn Txn, k, v []byte) error
+}
+
+var migrations []Migration
+
+// RegisterMigration adds m to the migrations run when the store
+// starts. Packages owning a prefix register the migrations
+// of their stored values on init.
+func RegisterMigration(m Migration) {
+	if m.Version == 0 || m.Version > SchemaVersion {
+		panic(errors.Errorf("migration %s has invalid version %d", m.Name, m.Version))
+	}
+
+	migrations = append(migrations, m)
+}
+
+// Version returns the schema version of the stored data. Data
+// stored before versioning was introduced is at version 0.
+func (s *Store) Version() (uint32, error) {
+	var version uint32
+	err := s.View(func(txn Txn) error {
+		var err error
+		version, err = getVersion(txn)
+		if err == ErrKeyNotFound {
+			return nil
+		}
+
+		return err
+	})
+
+	return version, err
+}
+
+// Migrate upgrades the stored data to SchemaVersion, one version
+// after the other. A fresh store is set to SchemaVersion directly.
+func (s *Store) Migrate() error {
+	version, err := s.Version()
+	if err != nil {
+		return err
+	}
+
+	if version == 0 && len(s.GetKeys()) == 0 {
+		return s.Update(func(txn Txn) error {
+			return setVersion(txn, SchemaVersion)
+		})
+	}
+
+	if version > SchemaVersion {
+		return ErrNewerSchema
+	}
+
+	for v := version + 1; v <= SchemaVersion; v++ {
+		if err := s.migrateVersion(v); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// migrateVersion runs the migrations to version v. Keys are migrated
+// in batches, each committed along with the progress made, so that
+// an interrupted migration resumes after the last committed batch
+// instead of migrating any key twice.
+func (s *Store) migrateVersion(v uint32) error {
+	var ms []Migration
+	for _, m := range migrations {
+		if m.Version == v {
+			ms = append(ms, m)
+		}
+	}
+
+	var p *migrationProgress
+	err := s.View(func(txn Txn) error {
+		var err error
+		p, err = getProgress(txn, v)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	for int(p.index) < len(ms) {
+		m := ms[p.index]
+		if p.last == nil {
+			log.WithFields(log.Fields{"version": v, "migration": m.Name}).Info("Migrating store")
+		}
+
+		var next *migrationProgress
+		err := s.Update(func(txn Txn) error {
+			var err error
+			next, err = migrateBatch(txn, m, p)
+			if err != nil {
+				return errors.Wrapf(err, "migration %s failed", m.Name)
+			}
+
+			return setProgress(txn, next)
+		})
+		if err != nil {
+			return err
+		}
+
+		p = next
+	}
+
+	return s.Update(func(txn Txn) error {
+		if err := txn.Delete(progressKey); err != nil {
+			return err
+		}
+
+		return setVersion(txn, v)
+	})
+}
+
+// migrateBatch migrates the keys following the last migrated key of
+// p, up to the MigrationBatchSize bound, returning the progress made.
+func migrateBatch(txn Txn, m Migration, p *migrationProgress) (*migrationProgress, error) {
+	// Start right after the last migrated key
+	var start []byte
+	if p.last != nil {
+		start = append(append([]byte{}, p.last...), 0)
+	}
+
+	var keys, values [][]byte
+	err := txn.IterateFrom(m.Prefix, start, func(k, v []byte) error {
+		if len(keys) == MigrationBatchSize {
+			return errBatchFull
+		}
+
+		keys = append(keys, k)
+		values = append(values, v)
+		return nil
+	})
+	if err != nil && err != errBatchFull {
+		return nil, err
+	}
+
+	ct := &countingTxn{Txn: txn}
+	for i, k := range keys {
+		if err := m.Migrate(ct, k, values[i]); err != nil {
+			return nil, err
+		}
+
+		if ct.writes >= MigrationBatchSize && i < len(keys)-1 {
+			return &migrationProgress{version: p.version, index: p.index, last: k[len(m.Prefix):]}, nil
+		}
+	}
+
+	if err == nil {
+		// The migration is done, on to the next one
+		return &migrationProgress{version: p.version, index: p.index + 1}, nil
+	}
+
+	last := keys[len(keys)-1][len(m.Prefix):]
+	return &migrationProgress{version: p.version, index: p.index, last: last}, nil
+}
+
+// countingTxn counts the keys written through it.
+type countingTxn struct {
+	Txn
+	writes int
+}
+
+func (t *countingTxn) Set(k []byte, v []byte) error {
+	t.writes++
+	return t.Txn.Set(k, v)
+}
+
+func (t *countingTxn) Delete(k []byte) error {
+	t.writes++
+	return t.Txn.Delete(k)
+}
+
+// migrationProgress tracks the migration to a version which is
+// underway, with the index of the migration being run and the
+// last key it migrated, with the prefix trimmed.
+type migrationProgress struct {
+	version uint32
+	index   uint32
+	last    []byte
+}
+
+func getProgress(txn Txn, version uint32) (*migrationProgress, error) {
+	v, err := txn.Get(progressKey)
+	if err != nil {
+		if err == ErrKeyNotFound {
+			return &migrationProgress{version: version}, nil
+		}
+
+		return nil, err
+	}
+
+	if len(v) < 8 || binary.BigEndian.Uint32(v[:4]) != version {
+		return nil, ErrCorrupt
+	}
+
+	p := &migrationProgress{version: version, index: binary.BigEndian.Uint32(v[4:8])}
+	if len(v) > 8 {
+		p.last = v[8:]
+	}
+
+	return p, nil
+}
+
+func setProgress(txn Txn, p *migrationProgress) error {
+	v := make([]byte, 8, 8+len(p.last))
+	binary.BigEndian.PutUint32(v[:4], p.version)
+	binary.BigEndian.PutUint32(v[4:8], p.index)
+
+	return txn.Set(progressKey, append(v, p.last...))
+}
+
+func getVersion(txn Txn) (uint32, error) {
+	v, err := txn.Get(versionKey)
+	if err != nil {
+		return 0, err
+	}
+
+	if len(v) != 4 {
+		return 0, ErrCorrupt
+	}
+
+	return binary.BigEndian.Uint32(v), nil
+}
+
+func setVersion(txn Txn, version uint32) error {
+	var v [4]byte
+	binary.BigEndian.PutUint32(v[:], version)
+
+	return txn.Set(versionKey, v[:])
+}
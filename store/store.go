@@ -1,120 +1,167 @@
 package store
 
 import (
-	"github.com/dgraph-io/badger"
+	"github.com/pkg/errors"
 )
 
 var (
 	store *Store
 )
 
+var (
+	ErrKeyNotFound = errors.New("key not found")
+	// ErrCorrupt is returned for stored values
+	// which cannot be decoded.
+	ErrCorrupt = errors.New("corrupt value in store")
+)
+
+// ReadWriter is implemented by both Store and Txn, so that the
+// block, account, wallet and pending stores can operate either
+// directly on the store, or within a shared transaction.
+type ReadWriter interface {
+	Get(k []byte) ([]byte, error)
+	Set(k []byte, v []byte) error
+	Delete(k []byte) error
+	GetPrefixKeys(prefix []byte) [][]byte
+	GetPrefixValues(prefix []byte) (map[string][]byte, error)
+	// Iterate calls fn for every key with the given
+	// prefix in order, until fn returns an error.
+	Iterate(prefix []byte, fn func(k, v []byte) error) error
+	// IterateFrom is like Iterate, starting at
+	// the first key from prefix+start on.
+	IterateFrom(prefix, start []byte, fn func(k, v []byte) error) error
+}
+
+// Txn groups reads and writes to the store, so that they
+// are either all committed or all discarded.
+type Txn interface {
+	ReadWriter
+}
+
+// Backend is the key-value database the store is kept in.
+// Get returns ErrKeyNotFound for missing keys.
+type Backend interface {
+	Open() error
+	Close() error
+	Update(fn func(Txn) error) error
+	View(fn func(Txn) error) error
+}
+
 type Store struct {
-	db      *badger.DB
-	dataDir string
+	db Backend
 }
 
+// NewStore returns a store persisted by badger in dataDir.
 func NewStore(dataDir string) *Store {
+	return NewStoreWithBackend(NewBadgerBackend(dataDir))
+}
+
+// NewMemoryStore returns a store which is only kept in memory.
+func NewMemoryStore() *Store {
+	return NewStoreWithBackend(NewMemoryBackend())
+}
+
+func NewStoreWithBackend(b Backend) *Store {
 	s := new(Store)
 
-	s.dataDir = dataDir
+	s.db = b
 
 	return s
 }
 
+// Start opens the store and migrates its data
+// to the current schema version.
 func (s *Store) Start() error {
-	opts := badger.DefaultOptions
-	opts.Dir = s.dataDir
-	opts.ValueDir = s.dataDir
-	db, err := badger.Open(opts)
-	if err != nil {
+	if err := s.Open(); err != nil {
 		return err
 	}
 
-	s.db = db
+	if err := s.Migrate(); err != nil {
+		s.Stop()
+		return err
+	}
 
 	return nil
 }
 
+// Open opens the store without migrating it.
+func (s *Store) Open() error {
+	return s.db.Open()
+}
+
 func (s *Store) Stop() {
 	s.db.Close()
 }
 
+// Update runs fn within a read-write transaction, which is
+// committed if fn returns nil, and discarded otherwise.
+func (s *Store) Update(fn func(Txn) error) error {
+	return s.db.Update(fn)
+}
+
+// View runs fn within a read-only transaction.
+func (s *Store) View(fn func(Txn) error) error {
+	return s.db.View(fn)
+}
+
 func (s *Store) Set(k []byte, v []byte) error {
-	return s.db.Update(func(txn *badger.Txn) error {
-		if err := txn.Set(k, v); err != nil {
-			return err
-		}
+	return s.Update(func(txn Txn) error {
+		return txn.Set(k, v)
+	})
+}
 
-		return nil
+func (s *Store) Delete(k []byte) error {
+	return s.Update(func(txn Txn) error {
+		return txn.Delete(k)
 	})
 }
 
 func (s *Store) Get(k []byte) ([]byte, error) {
 	var v []byte
-	txn := s.db.NewTransaction(false)
-	defer txn.Discard()
-
-	item, err := txn.Get(k)
-	if err != nil {
-		return nil, err
-	}
-
-	v, err = item.ValueCopy(nil)
-	if err != nil {
-		return nil, err
-	}
+	err := s.View(func(txn Txn) error {
+		var err error
+		v, err = txn.Get(k)
+		return err
+	})
 
-	return v, nil
+	return v, err
 }
 
 func (s *Store) GetKeys() [][]byte {
-	keys := make([][]byte, 0, 2)
-	txn := s.db.NewTransaction(false)
-	defer txn.Discard()
-
-	opts := badger.DefaultIteratorOptions
-	opts.PrefetchSize = 10
-	it := txn.NewIterator(opts)
-	for it.Rewind(); it.Valid(); it.Next() {
-		item := it.Item()
-		keys = append(keys, item.Key())
-	}
-
-	return keys
+	return s.GetPrefixKeys(nil)
 }
 
 func (s *Store) GetPrefixKeys(prefix []byte) [][]byte {
-	keys := make([][]byte, 0, 2)
-	txn := s.db.NewTransaction(false)
-	defer txn.Discard()
-
-	it := txn.NewIterator(badger.DefaultIteratorOptions)
-	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
-		item := it.Item()
-		keys = append(keys, item.Key())
-	}
+	var keys [][]byte
+	s.View(func(txn Txn) error {
+		keys = txn.GetPrefixKeys(prefix)
+		return nil
+	})
 
 	return keys
 }
 
 func (s *Store) GetPrefixValues(prefix []byte) (map[string][]byte, error) {
-	res := make(map[string][]byte)
-	txn := s.db.NewTransaction(false)
-	defer txn.Discard()
-
-	it := txn.NewIterator(badger.DefaultIteratorOptions)
-	for it.Seek(prefix); it.ValidForPrefix(prefix); it.Next() {
-		item := it.Item()
-		k := item.Key()
-		v, err := item.ValueCopy(nil)
-		if err != nil {
-			return nil, err
-		}
+	var res map[string][]byte
+	err := s.View(func(txn Txn) error {
+		var err error
+		res, err = txn.GetPrefixValues(prefix)
+		return err
+	})
 
-		res[string(k)] = v
-	}
+	return res, err
+}
+
+func (s *Store) Iterate(prefix []byte, fn func(k, v []byte) error) error {
+	return s.View(func(txn Txn) error {
+		return txn.Iterate(prefix, fn)
+	})
+}
 
-	return res, nil
+func (s *Store) IterateFrom(prefix, start []byte, fn func(k, v []byte) error) error {
+	return s.View(func(txn Txn) error {
+		return txn.IterateFrom(prefix, start, fn)
+	})
 }
 
 // Blocks that we cannot store due to not having their parent
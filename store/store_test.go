@@ -1,6 +1,8 @@
 package store
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"testing"
 
@@ -37,3 +39,233 @@ func TestSetGet(t *testing.T) {
 
 	os.RemoveAll("unittestdir")
 }
+
+func TestUpdate(t *testing.T) {
+	s := NewStore("unittestdir")
+	s.Start()
+	testUpdate(t, s)
+	s.Stop()
+	os.RemoveAll("unittestdir")
+
+	s = NewMemoryStore()
+	s.Start()
+	testUpdate(t, s)
+	s.Stop()
+}
+
+func testUpdate(t *testing.T, s *Store) {
+	err := s.Update(func(txn Txn) error {
+		if err := txn.Set([]byte("a"), []byte("1")); err != nil {
+			return err
+		}
+
+		return txn.Set([]byte("b"), []byte("2"))
+	})
+	require.Nil(t, err)
+
+	v, err := s.Get([]byte("b"))
+	require.Nil(t, err)
+	assert.Equal(t, []byte("2"), v)
+
+	// A failing transaction discards all of its writes
+	failed := errors.New("failed")
+	err = s.Update(func(txn Txn) error {
+		if err := txn.Set([]byte("c"), []byte("3")); err != nil {
+			return err
+		}
+
+		if err := txn.Delete([]byte("a")); err != nil {
+			return err
+		}
+
+		return failed
+	})
+	assert.Equal(t, failed, err)
+
+	_, err = s.Get([]byte("c"))
+	assert.Equal(t, ErrKeyNotFound, err)
+
+	v, err = s.Get([]byte("a"))
+	require.Nil(t, err)
+	assert.Equal(t, []byte("1"), v)
+
+	keys := s.GetPrefixKeys(nil)
+	assert.Equal(t, [][]byte{[]byte("a"), []byte("b"), versionKey}, keys)
+}
+
+func TestMemoryIterate(t *testing.T) {
+	s := NewMemoryStore()
+	s.Start()
+	defer s.Stop()
+
+	require.Nil(t, s.Set([]byte("p:b"), []byte("2")))
+	require.Nil(t, s.Set([]byte("q:a"), []byte("0")))
+
+	// Writes within a transaction are visible to its own iterators
+	var keys []string
+	err := s.Update(func(txn Txn) error {
+		if err := txn.Set([]byte("p:a"), []byte("1")); err != nil {
+			return err
+		}
+
+		return txn.Iterate([]byte("p:"), func(k, v []byte) error {
+			keys = append(keys, string(k)+"="+string(v))
+			return nil
+		})
+	})
+	require.Nil(t, err)
+	assert.Equal(t, []string{"p:a=1", "p:b=2"}, keys)
+
+	keys = nil
+	err = s.IterateFrom([]byte("p:"), []byte("a\x00"), func(k, v []byte) error {
+		keys = append(keys, string(k))
+		return nil
+	})
+	require.Nil(t, err)
+	assert.Equal(t, []string{"p:b"}, keys)
+
+	err = s.View(func(txn Txn) error {
+		return txn.Set([]byte("p:c"), []byte("3"))
+	})
+	assert.NotNil(t, err)
+}
+
+func TestMigrate(t *testing.T) {
+	s := NewMemoryStore()
+	s.Start()
+	defer s.Stop()
+
+	// A fresh store starts at the current version
+	v, err := s.Version()
+	require.Nil(t, err)
+	assert.Equal(t, SchemaVersion, v)
+
+	// Data without a version is migrated from version 0
+	require.Nil(t, s.Delete(versionKey))
+	require.Nil(t, s.Set([]byte("key"), []byte("value")))
+	v, err = s.Version()
+	require.Nil(t, err)
+	assert.Equal(t, uint32(0), v)
+
+	require.Nil(t, s.Migrate())
+	v, err = s.Version()
+	require.Nil(t, err)
+	assert.Equal(t, SchemaVersion, v)
+
+	require.Nil(t, s.Update(func(txn Txn) error {
+		return setVersion(txn, SchemaVersion+1)
+	}))
+	assert.Equal(t, ErrNewerSchema, s.Migrate())
+}
+
+func TestMigrateBatches(t *testing.T) {
+	s := NewMemoryStore()
+	s.Start()
+	defer s.Stop()
+
+	failed := false
+	failAt := fmt.Sprintf("m:%05d", 2*MigrationBatchSize+1)
+	RegisterMigration(Migration{
+		Version: SchemaVersion,
+		Name:    "test",
+		Prefix:  []byte("m:"),
+		Migrate: func(txn Txn, k, v []byte) error {
+			if string(k) == failAt && !failed {
+				failed = true
+				return errors.New("interrupted")
+			}
+
+			return txn.Set(k, append(v, 'x'))
+		},
+	})
+
+	count := 3*MigrationBatchSize + 1
+	require.Nil(t, s.Update(func(txn Txn) error {
+		for i := 0; i < count; i++ {
+			if err := txn.Set([]byte(fmt.Sprintf("m:%05d", i)), nil); err != nil {
+				return err
+			}
+		}
+
+		return setVersion(txn, SchemaVersion-1)
+	}))
+
+	// The batches committed before the failure are kept
+	assert.NotNil(t, s.Migrate())
+	v, err := s.Version()
+	require.Nil(t, err)
+	assert.Equal(t, SchemaVersion-1, v)
+
+	v0, err := s.Get([]byte("m:00000"))
+	require.Nil(t, err)
+	assert.Equal(t, []byte("x"), v0)
+
+	// The migration resumes without migrating any key twice
+	require.Nil(t, s.Migrate())
+	v, err = s.Version()
+	require.Nil(t, err)
+	assert.Equal(t, SchemaVersion, v)
+
+	res, err := s.GetPrefixValues([]byte("m:"))
+	require.Nil(t, err)
+	assert.Len(t, res, count)
+	for _, v := range res {
+		assert.Equal(t, []byte("x"), v)
+	}
+
+	_, err = s.Get(progressKey)
+	assert.Equal(t, ErrKeyNotFound, err)
+}
+
+func TestMigrateWriteBatches(t *testing.T) {
+	s := NewMemoryStore()
+	s.Start()
+	defer s.Stop()
+
+	// Each key writes two more, so batches end before
+	// as many keys as MigrationBatchSize are migrated
+	failed := false
+	failAt := fmt.Sprintf("w:%05d", MigrationBatchSize/2)
+	RegisterMigration(Migration{
+		Version: SchemaVersion,
+		Name:    "test writes",
+		Prefix:  []byte("w:"),
+		Migrate: func(txn Txn, k, v []byte) error {
+			if string(k) == failAt && !failed {
+				failed = true
+				return errors.New("interrupted")
+			}
+
+			for _, suffix := range []string{":a", ":b"} {
+				if err := txn.Set(append([]byte("x:"), append(k, suffix...)...), nil); err != nil {
+					return err
+				}
+			}
+
+			return txn.Set(k, append(v, 'x'))
+		},
+	})
+
+	require.Nil(t, s.Update(func(txn Txn) error {
+		for i := 0; i < MigrationBatchSize; i++ {
+			if err := txn.Set([]byte(fmt.Sprintf("w:%05d", i)), nil); err != nil {
+				return err
+			}
+		}
+
+		return setVersion(txn, SchemaVersion-1)
+	}))
+
+	assert.NotNil(t, s.Migrate())
+	v0, err := s.Get([]byte("w:00000"))
+	require.Nil(t, err)
+	assert.Equal(t, []byte("x"), v0)
+
+	require.Nil(t, s.Migrate())
+	res, err := s.GetPrefixValues([]byte("w:"))
+	require.Nil(t, err)
+	for _, v := range res {
+		assert.Equal(t, []byte("x"), v)
+	}
+	assert.Len(t, s.GetPrefixKeys([]byte("x:")), 2*MigrationBatchSize)
+}
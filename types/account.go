@@ -99,8 +99,8 @@ func PubKeyFromHex(s string) (PubKey, error) {
 }
 
 func PubKeyFromSlice(data []byte) PubKey {
-	var a PubKey
-	copy(a[:], data)
+	a := make(PubKey, ed25519.PublicKeySize)
+	copy(a, data)
 	return a
 }
 
@@ -0,0 +1,109 @@
+package wallet
+
+import (
+	"encoding/binary"
+	"sort"
+
+	"github.com/s1na/nano/store"
+	"github.com/s1na/nano/types"
+
+	"github.com/pkg/errors"
+)
+
+// walletVersion is the version of the stored wallet encoding.
+// A stored wallet is the version, the id, the length of the seed
+// and the seed itself, and the number of accounts as a big endian
+// uint32, followed by the length and private key of each account
+// ordered by address. Addresses are derived from the keys.
+const walletVersion byte = 1
+
+func encodeWallet(w *Wallet) []byte {
+	v := []byte{walletVersion}
+
+	id := make([]byte, 32)
+	copy(id, w.Id)
+	v = append(v, id...)
+	v = append(v, byte(len(w.Seed)))
+	v = append(v, w.Seed...)
+
+	addrs := make([]string, 0, len(w.Accounts))
+	for addr := range w.Accounts {
+		addrs = append(addrs, addr)
+	}
+	sort.Strings(addrs)
+
+	var n [4]byte
+	binary.BigEndian.PutUint32(n[:], uint32(len(addrs)))
+	v = append(v, n[:]...)
+	for _, addr := range addrs {
+		prv := w.Accounts[addr]
+		v = append(v, byte(len(prv)))
+		v = append(v, prv...)
+	}
+
+	return v
+}
+
+func decodeWallet(v []byte) (*Wallet, error) {
+	if len(v) < 1 {
+		return nil, store.ErrCorrupt
+	}
+
+	if v[0] != walletVersion {
+		return nil, errors.Errorf("unsupported wallet encoding version %d", v[0])
+	}
+
+	r := reader{v[1:], false}
+
+	w := NewWallet()
+	w.Id = types.PubKeyFromSlice(r.next(32))
+	w.Seed = types.PrvKeyFromSlice(r.next(int(r.byte())))
+
+	n := binary.BigEndian.Uint32(r.next(4))
+	for i := uint32(0); i < n && !r.short; i++ {
+		prv := types.PrvKeyFromSlice(r.next(int(r.byte())))
+		if r.short {
+			break
+		}
+
+		pub, _, err := types.KeypairFromPrvKey(prv)
+		if err != nil {
+			return nil, store.ErrCorrupt
+		}
+
+		w.Accounts[pub.Address()] = prv
+	}
+
+	if r.short || len(r.data) != 0 {
+		return nil, store.ErrCorrupt
+	}
+
+	return w, nil
+}
+
+// reader consumes a stored value, and records whether
+// it turned out to be shorter than expected.
+type reader struct {
+	data  []byte
+	short bool
+}
+
+// next returns a copy of the next n bytes, which are all
+// zeros if the value is too short.
+func (r *reader) next(n int) []byte {
+	res := make([]byte, n)
+	if len(r.data) < n {
+		r.short = true
+		r.data = nil
+		return res
+	}
+
+	copy(res, r.data)
+	r.data = r.data[n:]
+
+	return res
+}
+
+func (r *reader) byte() byte {
+	return r.next(1)[0]
+}
@@ -0,0 +1,28 @@
+package wallet
+
+import (
+	"bytes"
+	"encoding/gob"
+
+	"github.com/s1na/nano/store"
+)
+
+func init() {
+	store.RegisterMigration(store.Migration{
+		Version: 1,
+		Name:    "binary wallet encoding",
+		Prefix:  []byte("wallet:"),
+		Migrate: migrateGobWallet,
+	})
+}
+
+// migrateGobWallet re-encodes a wallet,
+// as they were gob encoded before version 1.
+func migrateGobWallet(txn store.Txn, k, v []byte) error {
+	var w *Wallet
+	if err := gob.NewDecoder(bytes.NewBuffer(v)).Decode(&w); err != nil {
+		return err
+	}
+
+	return txn.Set(k, encodeWallet(w))
+}
@@ -1,18 +1,15 @@
 package wallet
 
 import (
-	"bytes"
-	"encoding/gob"
-
 	"github.com/s1na/nano/store"
 	"github.com/s1na/nano/types"
 )
 
 type WalletStore struct {
-	s *store.Store
+	s store.ReadWriter
 }
 
-func NewWalletStore(store *store.Store) *WalletStore {
+func NewWalletStore(store store.ReadWriter) *WalletStore {
 	s := new(WalletStore)
 
 	s.s = store
@@ -21,13 +18,7 @@ func NewWalletStore(store *store.Store) *WalletStore {
 }
 
 func (s *WalletStore) SetWallet(w *Wallet) error {
-	var buf bytes.Buffer
-	enc := gob.NewEncoder(&buf)
-	if err := enc.Encode(w); err != nil {
-		return err
-	}
-
-	return s.s.Set(append([]byte("wallet:"), w.Id...), buf.Bytes())
+	return s.s.Set(append([]byte("wallet:"), w.Id...), encodeWallet(w))
 }
 
 func (s *WalletStore) GetWallet(id types.PubKey) (*Wallet, error) {
@@ -36,15 +27,7 @@ func (s *WalletStore) GetWallet(id types.PubKey) (*Wallet, error) {
 		return nil, err
 	}
 
-	buf := bytes.NewBuffer(v)
-	dec := gob.NewDecoder(buf)
-
-	var w *Wallet
-	if err = dec.Decode(&w); err != nil {
-		return nil, err
-	}
-
-	return w, nil
+	return decodeWallet(v)
 }
 
 func (s *WalletStore) GetWallets() (map[string]*Wallet, error) {
@@ -55,11 +38,8 @@ func (s *WalletStore) GetWallets() (map[string]*Wallet, error) {
 
 	wallets := make(map[string]*Wallet)
 	for k, v := range res {
-		buf := bytes.NewBuffer(v)
-		dec := gob.NewDecoder(buf)
-
-		var w *Wallet
-		if err = dec.Decode(&w); err != nil {
+		w, err := decodeWallet(v)
+		if err != nil {
 			return nil, err
 		}
 